@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -14,9 +21,24 @@ import (
 )
 
 var opts struct {
-	Host    string
-	Key     string
-	MACAddr string
+	Host        string
+	Key         string
+	Plaintext   bool
+	MACAddr     string
+	PowerOnWait time.Duration
+	InputsFile  string
+
+	CommandTTL    time.Duration
+	HoldImportant bool
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	WOLTargets   []string
+	WOLInterface string
+	WOLPassword  string
+	WOLRepeat    int
 }
 
 func main() {
@@ -26,75 +48,52 @@ func main() {
 			if opts.Host == "" {
 				return fmt.Errorf("must provide Host")
 			}
-			if opts.Key == "" {
+			if opts.Key == "" && !opts.Plaintext {
 				return fmt.Errorf("must provide Key")
 			}
 
 			ipopts := []ip.Option{
 				ip.WithLogger(log.New(log.WithFormat(log.JSONFormat))),
+				ip.WithCommandTTL(opts.CommandTTL),
+				ip.WithHoldImportant(opts.HoldImportant),
+				ip.WithDialTimeout(opts.DialTimeout),
+				ip.WithReadTimeout(opts.ReadTimeout),
+				ip.WithWriteTimeout(opts.WriteTimeout),
 			}
 			if opts.MACAddr != "" {
 				ipopts = append(ipopts, ip.WithMACAddress(opts.MACAddr))
 			}
-			client, err := ip.New(fmt.Sprintf("%s:9761", opts.Host), opts.Key, ipopts...)
+			if opts.Plaintext {
+				ipopts = append(ipopts, ip.WithCodec(ip.PlaintextCodec{}))
+			}
+			if opts.InputsFile != "" {
+				inputs, err := ip.LoadInputsFile(opts.InputsFile)
+				if err != nil {
+					return err
+				}
+				ipopts = append(ipopts, ip.WithInputs(inputs))
+			}
+			ipopts = append(ipopts, ip.WithWOL(ip.WOLConfig{
+				Targets:   opts.WOLTargets,
+				Interface: opts.WOLInterface,
+				Password:  opts.WOLPassword,
+				Repeat:    opts.WOLRepeat,
+			}))
+			client, err := ip.New(cmd.Context(), fmt.Sprintf("%s:9761", opts.Host), opts.Key, ipopts...)
 			if err != nil {
 				return err
 			}
+			defer client.Close()
 
-			e := echo.New()
-			e.HideBanner = true
-			e.HidePort = true
-			// TODO(chrism): better request logger
-			e.Use(middleware.Logger())
-			e.Use(middleware.Recover())
-
-			// TODO(chrism): Should be able to handle multiple devices.
-
-			// routes
-			e.GET("/button", func(c echo.Context) error {
-				client.Send(fmt.Sprintf("KEY_ACTION %s", c.QueryParam("name")))
-				return c.JSON(http.StatusOK, map[string]any{
-					"status": http.StatusOK,
-				})
-			})
-
-			e.GET("/state", func(c echo.Context) error {
-				return c.JSON(http.StatusOK, client.GetState())
-			})
-
-			e.GET("/input", func(c echo.Context) error {
-				if err := client.ChangeInput(c.QueryParam("name")); err != nil {
-					return c.JSON(http.StatusBadRequest, map[string]any{
-						"status": http.StatusBadRequest,
-						"error":  err.Error(),
-					})
-				}
-				return c.JSON(http.StatusOK, client.GetState())
-			})
-
-			e.GET("/poweroff", func(c echo.Context) error {
-				if err := client.PowerOff(); err != nil {
-					return c.JSON(http.StatusBadRequest, map[string]any{
-						"status": http.StatusBadRequest,
-						"error":  err.Error(),
-					})
-				}
-				return c.JSON(http.StatusOK, map[string]any{
-					"status": http.StatusOK,
-				})
-			})
-
-			e.GET("/poweron", func(c echo.Context) error {
-				if err := client.PowerOn(); err != nil {
-					return c.JSON(http.StatusBadRequest, map[string]any{
-						"status": http.StatusBadRequest,
-						"error":  err.Error(),
-					})
-				}
-				return c.JSON(http.StatusOK, map[string]any{
-					"status": http.StatusOK,
-				})
-			})
+			e := newServer(client)
+
+			go func() {
+				<-client.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				_ = e.Shutdown(ctx)
+			}()
 
 			// run
 			if err := e.Start(":8080"); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -106,9 +105,292 @@ func main() {
 
 	cmd.Flags().StringVarP(&opts.Host, "host", "H", "", "")
 	cmd.Flags().StringVar(&opts.Key, "key", "", "")
+	cmd.Flags().BoolVar(&opts.Plaintext, "plaintext", false, "use the unencrypted protocol of older models, in which case --key is not required")
 	cmd.Flags().StringVar(&opts.MACAddr, "mac-addr", "", "")
+	cmd.Flags().StringVar(&opts.InputsFile, "inputs", "", "JSON file with the catalog of inputs and apps used by /input")
+	cmd.Flags().DurationVar(&opts.CommandTTL, "command-ttl", 5*time.Second, "how long commands wait to be sent before expiring")
+	cmd.Flags().BoolVar(&opts.HoldImportant, "hold-important", false, "hold input, volume and mute commands until the connection is restored")
+	cmd.Flags().DurationVar(&opts.DialTimeout, "dial-timeout", 1*time.Second, "timeout for each connection attempt")
+	cmd.Flags().DurationVar(&opts.ReadTimeout, "read-timeout", 1*time.Second, "how long to wait for the device to respond to a command")
+	cmd.Flags().DurationVar(&opts.WriteTimeout, "write-timeout", 1*time.Second, "timeout for writing a command to the connection")
+	cmd.Flags().StringSliceVar(&opts.WOLTargets, "wol-target", nil, "WOL destination address (host or host:port), can be repeated")
+	cmd.Flags().StringVar(&opts.WOLInterface, "wol-interface", "", "send WOL to the subnet-directed broadcast address of this interface")
+	cmd.Flags().StringVar(&opts.WOLPassword, "wol-password", "", "WOL SecureOn password")
+	cmd.Flags().IntVar(&opts.WOLRepeat, "wol-repeat", 1, "number of WOL packets sent to each destination")
+	cmd.Flags().DurationVar(&opts.PowerOnWait, "poweron-wait", 0, "block /poweron until the device is controllable, up to this duration")
 
-	if err := cmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.ExecuteContext(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// newServer returns the HTTP server with all routes registered for client.
+func newServer(client *ip.Client) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	// TODO(chrism): better request logger
+	e.Use(middleware.Logger())
+	e.Use(middleware.Recover())
+
+	// TODO(chrism): Should be able to handle multiple devices.
+
+	// routes
+	e.GET("/button", func(c echo.Context) error {
+		key, err := ip.ParseKey(c.QueryParam("name"))
+		if err != nil {
+			return badRequest(c, err)
+		}
+		if err := client.PressKey(c.Request().Context(), key); err != nil {
+			return badRequest(c, err)
+		}
+		return ok(c)
+	})
+
+	e.GET("/keys", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, ip.Keys())
+	})
+
+	e.GET("/state", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/stats", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, client.QueueStats())
+	})
+
+	e.GET("/events", func(c echo.Context) error {
+		w := c.Response()
+		w.Header().Set(echo.HeaderContentType, "text/event-stream")
+		w.Header().Set(echo.HeaderCacheControl, "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Flush()
+
+		for ev := range client.Subscribe(c.Request().Context()) {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return err
+			}
+			w.Flush()
+		}
+		return nil
+	})
+
+	e.GET("/input", func(c echo.Context) error {
+		if err := client.ChangeInput(c.Request().Context(), c.QueryParam("name")); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/inputs", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, client.Inputs())
+	})
+
+	e.GET("/volume", func(c echo.Context) error {
+		if c.QueryParam("level") == "" {
+			return c.JSON(http.StatusOK, client.GetState())
+		}
+		level, err := strconv.Atoi(c.QueryParam("level"))
+		if err != nil {
+			return badRequest(c, fmt.Errorf("invalid volume level: %q", c.QueryParam("level")))
+		}
+		if err := client.SetVolume(c.Request().Context(), level); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/volume/up", func(c echo.Context) error {
+		steps, err := parseSteps(c.QueryParam("steps"))
+		if err != nil {
+			return badRequest(c, err)
+		}
+		if err := client.StepVolume(c.Request().Context(), steps); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/volume/down", func(c echo.Context) error {
+		steps, err := parseSteps(c.QueryParam("steps"))
+		if err != nil {
+			return badRequest(c, err)
+		}
+		if err := client.StepVolume(c.Request().Context(), -steps); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/mute", func(c echo.Context) error {
+		if err := client.Mute(c.Request().Context()); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/unmute", func(c echo.Context) error {
+		if err := client.Unmute(c.Request().Context()); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/mute/toggle", func(c echo.Context) error {
+		if err := client.ToggleMute(c.Request().Context()); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	// Any combination of settings can be changed at once, for example
+	// /picture?mode=cinema&energy=off.
+	e.GET("/picture", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if v := c.QueryParam("mode"); v != "" {
+			mode, err := ip.ParsePictureMode(v)
+			if err != nil {
+				return badRequest(c, err)
+			}
+			if err := client.SetPictureMode(ctx, mode); err != nil {
+				return badRequest(c, err)
+			}
+		}
+		if v := c.QueryParam("energy"); v != "" {
+			level, err := ip.ParseEnergySaving(v)
+			if err != nil {
+				return badRequest(c, err)
+			}
+			if err := client.SetEnergySaving(ctx, level); err != nil {
+				return badRequest(c, err)
+			}
+		}
+		if v := c.QueryParam("aspect"); v != "" {
+			ratio, err := ip.ParseAspectRatio(v)
+			if err != nil {
+				return badRequest(c, err)
+			}
+			if err := client.SetAspectRatio(ctx, ratio); err != nil {
+				return badRequest(c, err)
+			}
+		}
+		if v := c.QueryParam("backlight"); v != "" {
+			level, err := strconv.Atoi(v)
+			if err != nil {
+				return badRequest(c, fmt.Errorf("invalid backlight level: %q", v))
+			}
+			if err := client.SetBacklight(ctx, level); err != nil {
+				return badRequest(c, err)
+			}
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/channel", func(c echo.Context) error {
+		if number := c.QueryParam("number"); number != "" {
+			if err := client.SetChannel(c.Request().Context(), number); err != nil {
+				return badRequest(c, err)
+			}
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/channel/up", func(c echo.Context) error {
+		if err := client.ChannelUp(c.Request().Context()); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/channel/down", func(c echo.Context) error {
+		if err := client.ChannelDown(c.Request().Context()); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/screen/off", func(c echo.Context) error {
+		if err := client.ScreenOff(c.Request().Context()); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/screen/on", func(c echo.Context) error {
+		if err := client.ScreenOn(c.Request().Context()); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/screen/toggle", func(c echo.Context) error {
+		if err := client.ToggleScreen(c.Request().Context()); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/poweroff", func(c echo.Context) error {
+		if err := client.PowerOff(); err != nil {
+			return badRequest(c, err)
+		}
+		return ok(c)
+	})
+
+	e.GET("/poweron", func(c echo.Context) error {
+		if opts.PowerOnWait == 0 {
+			if err := client.PowerOn(); err != nil {
+				return badRequest(c, err)
+			}
+			return ok(c)
+		}
+		ctx, cancel := context.WithTimeout(c.Request().Context(), opts.PowerOnWait)
+		defer cancel()
+
+		if err := client.PowerOnAndWait(ctx); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	e.GET("/power/toggle", func(c echo.Context) error {
+		if err := client.TogglePower(); err != nil {
+			return badRequest(c, err)
+		}
+		return c.JSON(http.StatusOK, client.GetState())
+	})
+
+	return e
+}
+
+func ok(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{
+		"status": http.StatusOK,
+	})
+}
+
+func badRequest(c echo.Context, err error) error {
+	return c.JSON(http.StatusBadRequest, map[string]any{
+		"status": http.StatusBadRequest,
+		"error":  err.Error(),
+	})
+}
+
+// parseSteps parses the optional number of volume steps, defaulting to 1.
+func parseSteps(s string) (int, error) {
+	if s == "" {
+		return 1, nil
+	}
+	steps, err := strconv.Atoi(s)
+	if err != nil || steps < 1 {
+		return 0, fmt.Errorf("invalid volume steps: %q", s)
+	}
+	return steps, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+	"go.chrisrx.dev/webos/ip/iptest"
+)
+
+const testKey = "ABCD1234"
+
+func TestButtonRejectsInjectedCommand(t *testing.T) {
+	tv, err := iptest.NewServer(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tv.Close()
+
+	client, err := ip.New(context.Background(), tv.Addr, testKey, ip.WithPollQueries())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.WaitFor(ctx, func(s ip.State) bool { return s.Connected }); err != nil {
+		t.Fatal(err)
+	}
+	before := tv.Commands()
+
+	e := newServer(client)
+	for _, target := range []string{
+		"/button?name=ok%0dPOWER%20off",
+		"/button?name=ok%0aPOWER%20off",
+		"/button?name=ok%20POWER",
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: expected status %d, received %d", target, http.StatusBadRequest, rec.Code)
+		}
+	}
+
+	// Give anything that slipped through time to reach the TV.
+	time.Sleep(200 * time.Millisecond)
+	if after := tv.Commands(); !slices.Equal(before, after) {
+		t.Errorf("expected no commands to be sent, received %q", after[len(before):])
+	}
+	if !tv.State().Power {
+		t.Error("expected TV to remain powered on")
+	}
+}
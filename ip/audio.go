@@ -0,0 +1,77 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	MinVolume = 0
+	MaxVolume = 100
+)
+
+func clampVolume(level int) int {
+	return min(max(level, MinVolume), MaxVolume)
+}
+
+// Volume queries the device for the current volume level.
+func (c *Client) Volume(ctx context.Context) (int, error) {
+	resp, err := c.Do(ctx, "CURRENT_VOL")
+	if err != nil {
+		return 0, err
+	}
+	level, err := parseVolume(resp.Value)
+	if err != nil {
+		return 0, err
+	}
+	return int(level), nil
+}
+
+// SetVolume sets the absolute volume level. The level is clamped to the range
+// supported by the device and the change is confirmed by querying the current
+// volume afterwards.
+func (c *Client) SetVolume(ctx context.Context, level int) error {
+	level = clampVolume(level)
+	return c.apply(ctx, OperationVolume, fmt.Sprintf("VOLUME_CONTROL %d", level), "CURRENT_VOL", func(s State) bool {
+		return s.CurrentVolume == int64(level)
+	})
+}
+
+// StepVolume changes the volume relative to the current level. Positive steps
+// increase the volume, negative steps decrease it.
+func (c *Client) StepVolume(ctx context.Context, steps int) error {
+	level, err := c.Volume(ctx)
+	if err != nil {
+		return err
+	}
+	return c.SetVolume(ctx, level+steps)
+}
+
+func (c *Client) Mute(ctx context.Context) error {
+	return c.setMute(ctx, true)
+}
+
+func (c *Client) Unmute(ctx context.Context) error {
+	return c.setMute(ctx, false)
+}
+
+// ToggleMute inverts the current mute state, as reported by the device.
+func (c *Client) ToggleMute(ctx context.Context) error {
+	if _, err := c.Do(ctx, "MUTE_STATE"); err != nil {
+		return err
+	}
+	return c.setMute(ctx, !c.GetState().MuteState)
+}
+
+func (c *Client) setMute(ctx context.Context, mute bool) error {
+	return c.apply(ctx, OperationMute, fmt.Sprintf("VOLUME_MUTE %s", formatBool(mute)), "MUTE_STATE", func(s State) bool {
+		return s.MuteState == mute
+	})
+}
+
+func formatBool(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
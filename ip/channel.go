@@ -0,0 +1,84 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// liveTVApp is the CURRENT_APP value while the tuner is being watched.
+const liveTVApp = "com.webos.app.livetv"
+
+// channelPattern matches channel numbers that can be entered with the number
+// keys, including ATSC style major-minor numbers such as "7-1".
+var channelPattern = regexp.MustCompile(`^[0-9]{1,4}(-[0-9]{1,3})?$`)
+
+// SetChannel tunes to the channel number by entering it with the number keys,
+// switching to live TV first if necessary.
+func (c *Client) SetChannel(ctx context.Context, number string) error {
+	if !channelPattern.MatchString(number) {
+		return fmt.Errorf("%w: invalid channel number %q", ErrInvalidCommand, number)
+	}
+	if err := c.watchLiveTV(ctx); err != nil {
+		return err
+	}
+	for _, r := range number {
+		key := KeyDash
+		if r != '-' {
+			key = Key(fmt.Sprintf("number%c", r))
+		}
+		if err := c.PressKey(ctx, key); err != nil {
+			return err
+		}
+	}
+	if err := c.PressKey(ctx, KeyOK); err != nil {
+		return err
+	}
+	c.setChannel(number)
+	return nil
+}
+
+// ChannelUp tunes to the next channel. Since the device cannot be queried for
+// the channel, the current channel becomes unknown.
+func (c *Client) ChannelUp(ctx context.Context) error {
+	return c.stepChannel(ctx, KeyChannelUp)
+}
+
+func (c *Client) ChannelDown(ctx context.Context) error {
+	return c.stepChannel(ctx, KeyChannelDown)
+}
+
+// Channel returns the channel number last entered with SetChannel, or an
+// empty string if it is unknown.
+func (c *Client) Channel() string {
+	return c.GetState().CurrentChannel
+}
+
+func (c *Client) stepChannel(ctx context.Context, key Key) error {
+	if err := c.watchLiveTV(ctx); err != nil {
+		return err
+	}
+	if err := c.PressKey(ctx, key); err != nil {
+		return err
+	}
+	c.setChannel("")
+	return nil
+}
+
+// watchLiveTV switches to live TV unless it is already the current app.
+func (c *Client) watchLiveTV(ctx context.Context) error {
+	if c.GetState().CurrentApp == liveTVApp {
+		return nil
+	}
+	return c.apply(ctx, OperationInput, fmt.Sprintf("KEY_ACTION %s", KeyLiveTV), "CURRENT_APP", func(s State) bool {
+		return s.CurrentApp == liveTVApp
+	})
+}
+
+func (c *Client) setChannel(number string) {
+	c.updateState(func(s *State) {
+		s.CurrentChannel = number
+		s.UpdatedAt.CurrentChannel = time.Now()
+	})
+}
@@ -4,19 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net"
 	"os"
-	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-
-	"go.chrisrx.dev/group"
-	"go.chrisrx.dev/x/run"
 )
 
 type Option func(*Client)
@@ -33,64 +28,229 @@ func WithMACAddress(addr string) Option {
 	}
 }
 
+// WithQueueSize sets the number of commands that can be queued for each
+// priority.
+func WithQueueSize(n int) Option {
+	return func(client *Client) {
+		client.queueSize = n
+	}
+}
+
+// WithOverflowPolicy sets what happens to commands sent while the queue is
+// full. The default is OverflowBlock.
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(client *Client) {
+		client.overflow = p
+	}
+}
+
+// WithCommandTTL sets how long commands can wait to be sent, for example
+// while the device is disconnected, before they expire with ErrExpired. A TTL
+// of zero means commands only expire with the context they were sent with.
+func WithCommandTTL(ttl time.Duration) Option {
+	return func(client *Client) {
+		client.commandTTL = ttl
+	}
+}
+
+// WithHoldImportant exempts important commands (input changes, volume and
+// mute) from the command TTL, so that they are held until the connection is
+// restored rather than expiring.
+func WithHoldImportant(hold bool) Option {
+	return func(client *Client) {
+		client.holdImportant = hold
+	}
+}
+
+// WithWOL configures how Wake-on-LAN packets are sent by PowerOn.
+func WithWOL(cfg WOLConfig) Option {
+	return func(client *Client) {
+		client.wol = cfg
+	}
+}
+
 type Client struct {
 	mu        sync.Mutex
 	conn      net.Conn
 	connected atomic.Bool
-	enc       *Encoder
-	q         chan string
-	state     State
-
-	ctx    context.Context
-	cancel context.CancelFunc
-
-	addr    string
-	macAddr string
-	logger  *slog.Logger
+	keyErr    atomic.Bool
+	readyMu   sync.Mutex
+	ready     chan struct{}
+	wake      chan struct{}
+	codec     Codec
+	q         *queue
+	pending   pendingCommands
+	subs      subscribers
+
+	stateMu           sync.RWMutex
+	state             State
+	changed           chan struct{}
+	turningOnDeadline time.Time
+	turningOnTimer    *time.Timer
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	done     chan struct{}
+	closeErr error
+
+	addr          string
+	dialer        Dialer
+	dialTimeout   time.Duration
+	readTimeout   time.Duration
+	writeTimeout  time.Duration
+	macAddr       string
+	wol           WOLConfig
+	queueSize     int
+	overflow      OverflowPolicy
+	commandTTL    time.Duration
+	holdImportant bool
+	inputs        Inputs
+	retry         map[Operation]RetryPolicy
+	logger        *slog.Logger
+
+	pollQueries     []PollQuery
+	pollPaused      atomic.Bool
+	boostInterval   time.Duration
+	boostDuration   time.Duration
+	lastInteractive atomic.Int64
 }
 
-func New(addr, key string, opts ...Option) (*Client, error) {
-	enc, err := NewEncoder(key)
-	if err != nil {
-		return nil, err
-	}
+// New returns a client for the device at addr. The client runs until Close is
+// called or ctx is canceled.
+func New(ctx context.Context, addr, key string, opts ...Option) (*Client, error) {
 	c := &Client{
-		enc:    enc,
-		q:      make(chan string, 10),
-		addr:   addr,
-		logger: slog.Default(),
+		ready:        make(chan struct{}),
+		done:         make(chan struct{}),
+		changed:      make(chan struct{}),
+		wake:         make(chan struct{}, 1),
+		addr:         addr,
+		dialer:       &net.Dialer{},
+		dialTimeout:  defaultDialTimeout,
+		readTimeout:  defaultReadTimeout,
+		writeTimeout: defaultWriteTimeout,
+		queueSize:    defaultQueueSize,
+		commandTTL:   defaultCommandTTL,
+		inputs:       DefaultInputs,
+		logger:       slog.Default(),
+
+		pollQueries:   DefaultPollQueries,
+		boostInterval: defaultBoostInterval,
+		boostDuration: defaultBoostDuration,
 	}
 	for _, opt := range opts {
 		opt(c)
 	}
-	c.ctx, c.cancel = context.WithCancel(context.TODO())
+	if c.codec == nil {
+		enc, err := NewEncoder(key)
+		if err != nil {
+			return nil, err
+		}
+		c.codec = enc
+	}
+	for _, q := range c.pollQueries {
+		if err := ValidateCommand(q.Command); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.inputs.Validate(); err != nil {
+		return nil, err
+	}
+	c.q = newQueue(c.queueSize, c.overflow)
+	c.state.Power = PowerStateUnknown
+	c.ctx, c.cancel = context.WithCancel(ctx)
 
 	// The underlying tcp connection is established asynchronously to allow
 	// clients to be constructed even if the device is currently unavailable.
-	go run.Until(c.ctx, func() bool {
-		if err := c.connect(); err != nil {
-			c.logger.Error("connection attempt failed", slog.Any("error", err))
-			return false
-		}
-		return true
-	}, 1*time.Second)
+	c.goroutine(c.maintain)
 
 	// Query-based commands are scheduled periodically to update device state.
-	go run.Every(c.ctx, func() {
+	c.goroutine(c.pollLoop)
+
+	c.goroutine(c.process)
+
+	go func() {
+		<-c.ctx.Done()
+		c.shutdown()
+	}()
+
+	return c, nil
+}
+
+// goroutine runs fn in a goroutine that is waited on when the client shuts
+// down.
+func (c *Client) goroutine(fn func()) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		fn()
+	}()
+}
+
+const (
+	reconnectInitialInterval = 100 * time.Millisecond
+	reconnectMaxInterval     = 5 * time.Minute
+
+	// turningOnReconnectInterval caps the backoff while the device is
+	// turning on, so that it is connected to soon after it boots.
+	turningOnReconnectInterval = 1 * time.Second
+)
+
+// maintain keeps the connection to the device established, reconnecting with
+// backoff whenever the connection is lost. A reconnection attempt can be
+// triggered immediately by calling reconnect.
+func (c *Client) maintain() {
+	interval := reconnectInitialInterval
+	for {
 		if !c.connected.Load() {
+			// The key can never work once it has been rejected.
+			if c.keyErr.Load() {
+				return
+			}
+			if err := c.connect(); err != nil {
+				if c.ctx.Err() != nil {
+					return
+				}
+				c.setError(err)
+				if errors.Is(err, ErrInvalidKey) {
+					c.logger.Error("connection handshake failed, no further attempts will be made", slog.Any("error", err))
+					return
+				}
+				if c.GetState().Power == PowerStateTurningOn {
+					interval = min(interval, turningOnReconnectInterval)
+				}
+				c.logger.Error("connection attempt failed",
+					slog.Any("error", err),
+					slog.Duration("retry", interval),
+				)
+
+				select {
+				case <-time.After(interval):
+					interval = min(interval*2, reconnectMaxInterval)
+				case <-c.wake:
+					interval = reconnectInitialInterval
+				case <-c.ctx.Done():
+					return
+				}
+				continue
+			}
+			interval = reconnectInitialInterval
+		}
+		select {
+		case <-c.wake:
+		case <-c.ctx.Done():
 			return
 		}
-		c.Send("GET_MACADDRESS wired")
-		c.Send("GET_MACADDRESS wifi")
-		c.Send("MUTE_STATE")
-		c.Send("CURRENT_VOL")
-		c.Send("CURRENT_APP")
-		c.Send("GET_IPCONTROL_STATE")
-	}, 5*time.Second)
-
-	go c.process()
+	}
+}
 
-	return c, nil
+// reconnect signals maintain to check the connection, skipping any backoff
+// currently in progress.
+func (c *Client) reconnect() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
 }
 
 func (c *Client) connect() error {
@@ -99,22 +259,163 @@ func (c *Client) connect() error {
 
 	logger := c.logger.With(slog.String("addr", c.addr))
 	logger.Info("attempting connection...")
-	conn, err := (&net.Dialer{Timeout: 1 * time.Second}).DialContext(c.ctx, "tcp", c.addr)
+	conn, err := c.dial()
 	if err != nil {
 		return err
 	}
 	logger.Info("connection successful")
-	if err := conn.(*net.TCPConn).SetKeepAlive(true); err != nil {
+	fr := c.codec.NewDecoder(conn)
+	verified, err := c.verify(conn, fr)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	// Responses are read continuously from here on, so the read deadline
+	// used for the handshake is cleared.
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		_ = conn.Close()
 		return err
 	}
+	// The client may have been closed during the handshake, in which case
+	// the connection would never be closed by shutdown.
+	if c.ctx.Err() != nil {
+		_ = conn.Close()
+		return ErrClosed
+	}
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
 	c.conn = conn
-	c.connected.Store(true)
+	c.setConnected(true)
+	c.goroutine(func() { c.readLoop(conn, fr, verified) })
+	return nil
+}
+
+// readLoop reads responses from the connection until it is closed, routing
+// each one to the command it belongs to.
+// readLoop dispatches responses until the connection fails. Whether the
+// handshake was verified determines if responses that cannot be decoded are
+// attributed to the key being wrong.
+func (c *Client) readLoop(conn net.Conn, fr Decoder, verified bool) {
+	for {
+		plaintext, err := fr.ReadFrame()
+		if err != nil {
+			c.mu.Lock()
+			current := c.conn == conn
+			c.mu.Unlock()
+
+			// Errors are expected for connections that have been replaced
+			// or closed intentionally.
+			if !current || c.ctx.Err() != nil {
+				return
+			}
+			c.logger.Error("cannot read from connection", slog.Any("error", err))
+			if errors.Is(err, ErrBadPadding) || errors.Is(err, ErrWrongKey) {
+				// If the handshake went unanswered, responses that cannot be
+				// decoded at all mean the key, or the protocol, is wrong,
+				// which reconnecting cannot fix. Otherwise the key is known
+				// to work, and the stream is only out of sync.
+				if !verified {
+					c.keyErr.Store(true)
+					err = fmt.Errorf("%w: %w", ErrInvalidKey, err)
+				}
+				c.mu.Lock()
+				if c.conn == conn {
+					c.conn = nil
+				}
+				c.mu.Unlock()
+				_ = conn.Close()
+			}
+			c.pending.fail(fmt.Errorf("%w: %w", ErrNoResponse, err))
+			c.setConnected(false)
+			c.setError(err)
+			c.reconnect()
+			return
+		}
+		c.dispatch(strings.TrimSpace(string(plaintext)))
+	}
+}
+
+// ErrInvalidKey is returned when the device responds with data that cannot be
+// decrypted using the key provided to New. Once detected, the client stops
+// attempting to connect since the key can never work.
+var ErrInvalidKey = errors.New("invalid ip control key")
+
+// ErrClosed is returned for commands sent after the client is closed, and for
+// any commands that were still queued or waiting for a response at the time.
+var ErrClosed = errors.New("client closed")
+
+// verify performs a handshake with a harmless query to ensure that the
+// responses from the device can be decrypted. It reports whether the device
+// responded, since a device may also ignore a handshake it cannot decrypt.
+func (c *Client) verify(conn net.Conn, fr Decoder) (verified bool, err error) {
+	resp, err := c.handshake(conn, fr)
+	switch {
+	case errors.Is(err, ErrBadPadding), errors.Is(err, ErrWrongKey):
+		c.keyErr.Store(true)
+		return false, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	case errors.Is(err, ErrNoResponse):
+		// The device may not respond at all when the key is wrong, but that
+		// isn't conclusive enough to stop attempting to connect.
+		c.logger.Warn("no response to connection handshake")
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	if !parseBool(resp) {
+		c.logger.Error("ip control state is off")
+	}
+	return true, nil
+}
+
+// Err returns ErrInvalidKey if the device has rejected the key, otherwise it
+// returns nil.
+func (c *Client) Err() error {
+	if c.keyErr.Load() {
+		return ErrInvalidKey
+	}
 	return nil
 }
 
+// Close stops the client and waits for all of its goroutines to exit. Any
+// commands that have not completed fail with ErrClosed. It is safe to call
+// Close more than once, and regardless of whether the device was ever
+// reached.
 func (c *Client) Close() error {
 	c.cancel()
-	return c.conn.Close()
+	<-c.done
+	return c.closeErr
+}
+
+// Done returns a channel that is closed once the client has shut down, either
+// because Close was called or the context passed to New was canceled.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// shutdown releases everything held by the client once its context is done.
+func (c *Client) shutdown() {
+	defer close(c.done)
+
+	c.mu.Lock()
+	if c.conn != nil {
+		c.closeErr = c.conn.Close()
+	}
+	c.mu.Unlock()
+
+	c.stateMu.Lock()
+	if c.turningOnTimer != nil {
+		c.turningOnTimer.Stop()
+	}
+	c.stateMu.Unlock()
+
+	c.pending.fail(ErrClosed)
+	c.subs.close()
+	c.wg.Wait()
+
+	// Nothing is processing the queue anymore, so anything left in it, or
+	// pushed from here on, is rejected.
+	c.q.close()
 }
 
 type State struct {
@@ -123,9 +424,59 @@ type State struct {
 	MuteState       bool
 	CurrentVolume   int64
 	CurrentApp      string
+	Power           PowerState
+
+	// CurrentChannel is the channel number last entered by the client. It is
+	// empty when unknown, such as after changing channels up or down or
+	// switching away from live TV.
+	CurrentChannel string
+
+	// The device cannot be queried for its picture settings, so these are
+	// the values last set by the client. They are empty until set.
+	PictureMode  PictureMode
+	EnergySaving EnergySaving
+	AspectRatio  AspectRatio
+	Backlight    int64
+
+	// ScreenMuted reports whether the screen was turned off by the client,
+	// which the device also resets when it is powered off.
+	ScreenMuted bool
+
+	// Connected reports whether there is currently a connection established
+	// with the device.
+	Connected bool
+
+	// LastError is the most recent error encountered communicating with the
+	// device.
+	LastError string
+
+	// UpdatedAt records when each field was last updated. A zero value means
+	// the field has never been updated.
+	UpdatedAt StateTimestamps
 }
 
+type StateTimestamps struct {
+	MACAddressWired time.Time
+	MACAddressWifi  time.Time
+	MuteState       time.Time
+	CurrentVolume   time.Time
+	CurrentApp      time.Time
+	CurrentChannel  time.Time
+	Power           time.Time
+	PictureMode     time.Time
+	EnergySaving    time.Time
+	AspectRatio     time.Time
+	Backlight       time.Time
+	ScreenMuted     time.Time
+	Connected       time.Time
+	LastError       time.Time
+}
+
+// GetState returns a snapshot of the current device state.
 func (c *Client) GetState() State {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
 	return c.state
 }
 
@@ -136,64 +487,184 @@ func (c *Client) process() {
 	defer timer.Stop()
 
 	for {
+		req, err := c.q.pop(c.ctx)
+		if err != nil {
+			return
+		}
+		<-timer.C
+		timer.Reset(sendInterval)
+
+		if req.canceled.Load() {
+			continue
+		}
+		if !c.waitConnected(req) {
+			continue
+		}
+
+		logger := c.logger.With(slog.String("command", req.command))
+		resp, err := c.send(req.command)
+		if c.ctx.Err() != nil {
+			req.done(Response{}, &CommandError{Command: req.command, Err: ErrClosed})
+			return
+		}
+		if err != nil && !errors.Is(err, ErrNoResponse) {
+			req.done(Response{}, &CommandError{Command: req.command, Err: err})
+			c.setConnected(false)
+			c.setError(err)
+			c.logger.Error("cannot send command", slog.Any("error", err))
+			c.reconnect()
+			continue
+		}
+		if err != nil {
+			req.done(Response{}, &CommandError{Command: req.command, Err: err})
+			continue
+		}
+		logger = logger.With(slog.String("response", resp))
+		if err := c.update(req.command, resp); err != nil {
+			logger.Error("invalid command", slog.Any("error", err))
+			c.setError(err)
+			req.done(Response{}, &CommandError{Command: req.command, Response: resp, Err: err})
+			continue
+		}
+		req.done(Response{Command: req.command, Value: resp}, nil)
+	}
+}
+
+// waitConnected holds the request until the connection is available. While
+// waiting, the request and any queued requests that pass their deadline are
+// expired. It returns false if the request should not be sent.
+func (c *Client) waitConnected(req *request) bool {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		if req.canceled.Load() {
+			return false
+		}
+		if req.expired(time.Now()) {
+			c.q.expired.Add(1)
+			c.logger.Warn("command expired", slog.String("command", req.command))
+			req.done(Response{}, &CommandError{Command: req.command, Err: ErrExpired})
+			return false
+		}
 		select {
-		case cmd := <-c.q:
-			<-timer.C
-			timer.Reset(sendInterval)
-
-			logger := c.logger.With(slog.String("command", cmd))
-			resp, err := c.send(cmd)
-			if err != nil {
-				c.connected.Store(false)
-				c.logger.Error("cannot send command", slog.Any("error", err))
-
-				for attempt, err := range run.Retry(c.ctx, c.connect, run.RetryOptions{
-					InitialInterval: 100 * time.Millisecond,
-					MaxInterval:     5 * time.Minute,
-				}) {
-					c.logger.Debug("connection attempt failed",
-						slog.Any("error", err),
-						slog.Int("attempt", attempt),
-					)
-				}
-				continue
-			}
-			logger = logger.With(slog.String("response", resp))
-			switch cmd {
-			case "GET_MACADDRESS wired":
-				c.state.MACAddressWired = resp
-			case "GET_MACADDRESS wifi":
-				c.state.MACAddressWifi = resp
-			case "MUTE_STATE":
-				c.state.MuteState = parseBool(strings.TrimPrefix(resp, "MUTE:"))
-			case "CURRENT_VOL":
-				volume := strings.TrimPrefix(resp, "VOL:")
-				if volume == "" {
-					continue
-				}
-				i, err := strconv.ParseInt(volume, 10, 64)
-				if err != nil {
-					logger.Error("cannot parse command response", slog.Any("error", err))
-					continue
-				}
-				c.state.CurrentVolume = i
-			case "CURRENT_APP":
-				c.state.CurrentApp = strings.TrimPrefix(resp, "APP:")
-			case "GET_IPCONTROL_STATE":
-				if !parseBool(resp) {
-					logger.Error("ip control state is off")
-				}
-			default:
-				if resp != "OK" {
-					logger.Error("invalid command")
-				}
-			}
+		case <-c.connectedCh():
+			return true
+		case <-ticker.C:
+			c.q.expire(time.Now())
 		case <-c.ctx.Done():
+			req.done(Response{}, &CommandError{Command: req.command, Err: ErrClosed})
+			return false
+		}
+	}
+}
+
+// update applies the response of a query-based command to the device state.
+// Responses for any other command are expected to be a simple OK/NG.
+func (c *Client) update(command, resp string) error {
+	if resp == "NG" {
+		return ErrNG
+	}
+	now := time.Now()
+	switch command {
+	case "GET_MACADDRESS wired":
+		c.updateState(func(s *State) {
+			s.MACAddressWired = resp
+			s.UpdatedAt.MACAddressWired = now
+		})
+	case "GET_MACADDRESS wifi":
+		c.updateState(func(s *State) {
+			s.MACAddressWifi = resp
+			s.UpdatedAt.MACAddressWifi = now
+		})
+	case "MUTE_STATE":
+		c.updateState(func(s *State) {
+			s.MuteState = parseBool(strings.TrimPrefix(resp, "MUTE:"))
+			s.UpdatedAt.MuteState = now
+		})
+	case "CURRENT_VOL":
+		if strings.TrimPrefix(resp, "VOL:") == "" {
+			return nil
+		}
+		i, err := parseVolume(resp)
+		if err != nil {
+			return err
+		}
+		c.updateState(func(s *State) {
+			s.CurrentVolume = i
+			s.UpdatedAt.CurrentVolume = now
+		})
+	case "CURRENT_APP":
+		c.updateState(func(s *State) {
+			s.CurrentApp = strings.TrimPrefix(resp, "APP:")
+			s.UpdatedAt.CurrentApp = now
+			// The channel can change without the client knowing once the
+			// device leaves live TV, so it is no longer known.
+			if s.CurrentApp != liveTVApp {
+				s.CurrentChannel = ""
+				s.UpdatedAt.CurrentChannel = time.Time{}
+			}
+		})
+	case "GET_IPCONTROL_STATE":
+		if !parseBool(resp) {
+			c.logger.Error("ip control state is off")
+			return nil
+		}
+		c.transitionPower(func(PowerState) PowerState { return PowerStateOn })
+	default:
+		if resp != "OK" {
+			return fmt.Errorf("unexpected response: %q", resp)
+		}
+		c.record(command, now)
+	}
+	return nil
+}
+
+// record applies a command that the device accepted to the device state, for
+// settings that cannot be queried.
+func (c *Client) record(command string, now time.Time) {
+	verb, arg, _ := strings.Cut(command, " ")
+	switch verb {
+	case "PICTURE_MODE":
+		c.updateState(func(s *State) {
+			s.PictureMode = PictureMode(arg)
+			s.UpdatedAt.PictureMode = now
+		})
+	case "ENERGY_SAVING":
+		c.updateState(func(s *State) {
+			s.EnergySaving = EnergySaving(arg)
+			s.UpdatedAt.EnergySaving = now
+		})
+	case "ASPECT_RATIO":
+		c.updateState(func(s *State) {
+			s.AspectRatio = AspectRatio(arg)
+			s.UpdatedAt.AspectRatio = now
+		})
+	case "PICTURE_BACKLIGHT":
+		level, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
 			return
 		}
+		c.updateState(func(s *State) {
+			s.Backlight = level
+			s.UpdatedAt.Backlight = now
+		})
+	case "SCREEN_MUTE":
+		c.updateState(func(s *State) {
+			s.ScreenMuted = ScreenMute(arg) != ScreenMuteOff
+			s.UpdatedAt.ScreenMuted = now
+		})
 	}
 }
 
+func parseVolume(resp string) (int64, error) {
+	i, err := strconv.ParseInt(strings.TrimPrefix(resp, "VOL:"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse command response: %w", err)
+	}
+	return i, nil
+}
+
 func parseBool(resp string) bool {
 	switch strings.ToLower(resp) {
 	case "on":
@@ -204,148 +675,174 @@ func parseBool(resp string) bool {
 }
 
 func (c *Client) send(command string) (string, error) {
+	pc := c.pending.add(command)
+	if err := c.write(command); err != nil {
+		c.pending.remove(pc)
+		return "", err
+	}
+	timer := time.NewTimer(c.readTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-pc.result:
+		return r.resp.Value, r.err
+	case <-c.ctx.Done():
+		c.pending.remove(pc)
+		return "", ErrClosed
+	case <-timer.C:
+		// If the read deadline is exceeded, it can be assumed that the write
+		// to the connection was successful. This indicates that most likely
+		// the connection is still open but the device didn't return a response
+		// due to, for example, receiving an invalid command. It is important
+		// to return ErrNoResponse in this situation to prevent signaling that
+		// the connection should attempt reconnecting.
+		if !c.pending.timeout(pc, time.Now()) {
+			r := <-pc.result
+			return r.resp.Value, r.err
+		}
+		return "", ErrNoResponse
+	}
+}
+
+func (c *Client) write(command string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if err := c.conn.SetWriteDeadline(time.Now().Add(5 * time.Millisecond)); err != nil {
-		return "", err
+	// The connection is cleared by readLoop when it is dropped.
+	if c.conn == nil {
+		return net.ErrClosed
+	}
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+		return err
 	}
-	if _, err := c.conn.Write(c.enc.Encode([]byte(command))); err != nil {
+	_, err := c.conn.Write(c.codec.Encode([]byte(command)))
+	return err
+}
+
+// handshake sends a query on a new connection and reads the response
+// directly, before responses are being read by readLoop.
+func (c *Client) handshake(conn net.Conn, fr Decoder) (string, error) {
+	if err := conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
 		return "", err
 	}
-	if err := c.conn.SetReadDeadline(time.Now().Add(1000 * time.Millisecond)); err != nil {
+	if _, err := conn.Write(c.codec.Encode([]byte("GET_IPCONTROL_STATE"))); err != nil {
 		return "", err
 	}
-	b := make([]byte, 1024)
-	n, err := c.conn.Read(b)
-	if err != nil {
-		// If the read deadline is exceeded, it can be assumed that the write
-		// to the connection was successful. This indicates that most likely
-		// the connection is still open but the device didn't return a response
-		// due to, for example, receiving an invalid command. It is important
-		// to return no error in this situation to prevent signaling that the
-		// connection should attempt reconnecting.
-		if err == io.EOF || errors.Is(err, os.ErrDeadlineExceeded) {
-			return "", nil
-		}
+	if err := conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
 		return "", err
 	}
-	plaintext, err := c.enc.Decode(b[:n])
+	plaintext, err := fr.ReadFrame()
 	if err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return "", ErrNoResponse
+		}
 		return "", err
 	}
 	return strings.TrimSpace(string(plaintext)), nil
 }
 
-func (c *Client) MustSend(ctx context.Context, command string) error {
+// Do sends the command to the device and waits for its response. A
+// *CommandError is returned if the device rejects the command (ErrNG) or does
+// not respond (ErrNoResponse).
+func (c *Client) Do(ctx context.Context, command string) (Response, error) {
+	if err := ValidateCommand(command); err != nil {
+		return Response{}, err
+	}
+	if err := c.Err(); err != nil {
+		return Response{}, err
+	}
+	c.lastInteractive.Store(time.Now().UnixNano())
+	req := newRequest(command, PriorityInteractive, c.deadline(ctx, command), true)
+	if err := c.q.push(ctx, req); err != nil {
+		return Response{}, err
+	}
 	select {
-	case c.q <- command:
-		return nil
+	case r := <-req.waiters[0]:
+		return r.resp, r.err
 	case <-ctx.Done():
-		return ctx.Err()
+		// The command is dropped if it is still queued, so that it isn't
+		// sent on behalf of a caller that has given up on it.
+		req.cancel()
+		return Response{}, ctx.Err()
+	}
+}
+
+// MustSend queues the command without waiting for a response, blocking until
+// there is room in the queue or ctx is done. Once queued, the command expires
+// according to the command TTL, regardless of ctx. A nil error only means the
+// command was queued: nothing is reported if it later expires before being
+// sent, or if the device rejects it. Use Do when the outcome matters.
+func (c *Client) MustSend(ctx context.Context, command string) error {
+	if err := ValidateCommand(command); err != nil {
+		return err
+	}
+	if err := c.Err(); err != nil {
+		return err
 	}
+	c.lastInteractive.Store(time.Now().UnixNano())
+	return c.q.push(ctx, newRequest(command, PriorityInteractive, c.deadline(c.ctx, command), false))
 }
 
 const defaultCommandTimeout = 100 * time.Millisecond
 
-func (c *Client) Send(command string) {
+// Send queues the command without waiting for a response. An error is
+// returned if the command could not be queued within defaultCommandTimeout.
+// Like MustSend, it cannot report expiry or rejection by the device, so Do
+// should be used when the outcome matters.
+func (c *Client) Send(command string) error {
 	ctx, cancel := context.WithTimeout(c.ctx, defaultCommandTimeout)
 	defer cancel()
 
-	select {
-	case c.q <- command:
-	case <-ctx.Done():
-		c.logger.Debug("sending command timed out",
+	if err := c.MustSend(ctx, command); err != nil {
+		c.logger.Warn("cannot send command",
 			slog.String("command", command),
-			slog.Duration("timeout", defaultCommandTimeout),
+			slog.Any("error", err),
 		)
+		return err
 	}
+	return nil
 }
 
-var (
-	inputs = []string{
-		"hdmi1",
-		"hdmi2",
-		"hdmi3",
-		"hdmi4",
-		"atv",
-		"dtv",
-		"av1",
-		"component1",
+// deadline returns when a command sent now should expire if it hasn't been
+// sent, which is the sooner of the command TTL and the context deadline.
+func (c *Client) deadline(ctx context.Context, command string) time.Time {
+	var deadline time.Time
+	if c.commandTTL > 0 && !(c.holdImportant && isImportant(command)) {
+		deadline = time.Now().Add(c.commandTTL)
 	}
-
-	inputNameMap = map[string]string{
-		"hdmi1":      "hdmi1", // com.webos.app.hdmi1
-		"hdmi2":      "hdmi2",
-		"hdmi3":      "hdmi3",
-		"hdmi4":      "hdmi4",
-		"atv":        "atv",
-		"dtv":        "dtv",
-		"av1":        "av1",
-		"component1": "component1",
-		"youtube":    "youtube.leanback.v4",
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
 	}
-)
-
-func (c *Client) ChangeInput(input string) error {
-	name, ok := inputNameMap[input]
-	if !ok {
-		return fmt.Errorf("invalid input name: %q", input)
-	}
-	var command string
-	if slices.Contains(inputs, name) {
-		command = fmt.Sprintf("INPUT_SELECT %s", name)
-	} else {
-		command = fmt.Sprintf("APP_LAUNCH %s", name)
-	}
-	c.logger.Debug("input command",
-		slog.String("input", input),
-		slog.String("command", command),
-	)
-	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
-	defer cancel()
-
-	if err := c.MustSend(ctx, command); err != nil {
-		return err
-	}
-
-	ready := make(chan struct{})
-	go run.Until(ctx, func() bool {
-		if strings.Contains(c.state.CurrentApp, input) {
-			close(ready)
-			return true
-		}
-		c.Send(command)
-		return false
-	}, 100*time.Millisecond)
+	return deadline
+}
 
-	select {
-	case <-ready:
-		return nil
-	case <-ctx.Done():
-		return fmt.Errorf("timed out waiting for input change: %q", input)
+// poll queues a query used to update device state in the background.
+func (c *Client) poll(command string) {
+	if err := c.q.push(c.ctx, newRequest(command, PriorityPoll, c.deadline(c.ctx, command), false)); err != nil {
+		c.logger.Warn("dropped poll", slog.Any("error", err))
 	}
 }
 
-func (c *Client) PowerOff() error {
-	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
-	defer cancel()
-
-	return c.MustSend(ctx, "POWER off")
+// QueueStats returns counters for the command queue.
+func (c *Client) QueueStats() QueueStats {
+	return c.q.stats()
 }
 
-func (c *Client) PowerOn() error {
-	if c.state.MACAddressWifi == "" && c.state.MACAddressWired == "" && c.macAddr == "" {
-		return fmt.Errorf("mac address must be provided to send WOL packet")
-	}
-	g := group.New(c.ctx)
-	for _, addr := range []string{c.state.MACAddressWifi, c.state.MACAddressWired, c.macAddr} {
-		if addr == "" {
-			continue
-		}
-		g.Go(func(ctx context.Context) error {
-			return SendWOLPacket(strings.ToLower(addr), 5*time.Second)
-		})
+// ChangeInput selects the input or app from the catalog with the provided
+// name, and waits until the device reports it as the current app.
+func (c *Client) ChangeInput(ctx context.Context, name string) error {
+	input, err := c.inputs.Lookup(name)
+	if err != nil {
+		return err
 	}
-	return g.Wait()
+	command := input.Command()
+	expected := input.Expected()
+	c.logger.Debug("input command",
+		slog.String("input", name),
+		slog.String("command", command),
+		slog.String("expected", expected),
+	)
+	return c.apply(ctx, OperationInput, command, "CURRENT_APP", func(s State) bool {
+		return s.CurrentApp == expected
+	})
 }
@@ -0,0 +1,403 @@
+package ip_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+	"go.chrisrx.dev/webos/ip/iptest"
+)
+
+const testKey = "ABCD1234"
+
+// newTestClient starts a fake TV and returns a client that is connected to
+// it. Both are closed when the test finishes.
+func newTestClient(t *testing.T, opts ...ip.Option) (*iptest.Server, *ip.Client) {
+	t.Helper()
+
+	tv, err := iptest.NewServer(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tv.Close() })
+
+	client, err := ip.New(context.Background(), tv.Addr, testKey, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	waitFor(t, client, func(s ip.State) bool { return s.Connected })
+	return tv, client
+}
+
+func waitFor(t *testing.T, client *ip.Client, predicate func(ip.State) bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.WaitFor(ctx, predicate); err != nil {
+		t.Fatalf("%v: %+v", err, client.GetState())
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	tv, client := newTestClient(t, ip.WithPollQueries(
+		ip.PollQuery{Command: "CURRENT_VOL", Interval: 100 * time.Millisecond},
+		ip.PollQuery{Command: "MUTE_STATE", Interval: 100 * time.Millisecond},
+		ip.PollQuery{Command: "CURRENT_APP", Interval: 100 * time.Millisecond},
+	))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; ctx.Err() == nil; n++ {
+				command := "CURRENT_VOL"
+				if n%2 == 0 {
+					command = fmt.Sprintf("VOLUME_CONTROL %d", (i*10+n)%ip.MaxVolume)
+				}
+				if _, err := client.Do(context.Background(), command); err != nil {
+					errs <- fmt.Errorf("%s: %w", command, err)
+					return
+				}
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				_ = client.GetState()
+				_ = client.QueueStats()
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				subctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+				for range client.Subscribe(subctx) {
+				}
+				cancel()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			tv.SetState(func(s *iptest.State) { s.Muted = !s.Muted })
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Once the concurrent commands have settled, polling catches up with the
+	// final state of the TV.
+	volume := tv.State().Volume
+	waitFor(t, client, func(s ip.State) bool { return s.CurrentVolume == int64(volume) })
+}
+
+func TestCloseDoesNotLeakGoroutines(t *testing.T) {
+	tv, err := iptest.NewServer(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tv.Close()
+
+	wol, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wol.Close()
+
+	before := runtime.NumGoroutine()
+	for range 20 {
+		client, err := ip.New(context.Background(), tv.Addr, testKey,
+			ip.WithMACAddress("a0:b1:c2:d3:e4:01"),
+			ip.WithWOL(ip.WOLConfig{Targets: []string{wol.LocalAddr().String()}}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, client, func(s ip.State) bool { return s.Connected })
+		_ = client.Subscribe(context.Background())
+		if _, err := client.Do(context.Background(), "CURRENT_VOL"); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.PowerOn(); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The fake TV notices closed connections asynchronously.
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		buf := make([]byte, 1<<20)
+		t.Fatalf("expected at most %d goroutines, received %d:\n%s", before, after, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestCloseUnreachable(t *testing.T) {
+	// Dialing blocks until it is canceled, like a device that is not on the
+	// network.
+	dialer := ip.DialerFunc(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	client, err := ip.New(context.Background(), "192.0.2.1:9761", testKey,
+		ip.WithDialer(dialer),
+		ip.WithDialTimeout(time.Minute),
+		ip.WithCommandTTL(time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := client.Subscribe(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.Do(context.Background(), "CURRENT_VOL")
+		result <- err
+	}()
+	// Give the command time to be queued.
+	time.Sleep(100 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() { closed <- client.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	if err := <-result; !errors.Is(err, ip.ErrClosed) {
+		t.Errorf("expected pending command to fail with ErrClosed, received %v", err)
+	}
+	if _, err := client.Do(context.Background(), "CURRENT_VOL"); !errors.Is(err, ip.ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, received %v", err)
+	}
+	for range events {
+	}
+	if _, ok := <-client.Subscribe(context.Background()); ok {
+		t.Error("expected subscription after Close to be closed")
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Error("expected Done to be closed")
+	}
+}
+
+func TestChannelClearedOutsideLiveTV(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.SetChannel(ctx, "12-1"); err != nil {
+		t.Fatal(err)
+	}
+	if state := client.GetState(); state.CurrentChannel != "12-1" || state.UpdatedAt.CurrentChannel.IsZero() {
+		t.Fatalf("expected channel 12-1, received %q updated at %v", state.CurrentChannel, state.UpdatedAt.CurrentChannel)
+	}
+
+	if err := client.ChangeInput(ctx, "hdmi1"); err != nil {
+		t.Fatal(err)
+	}
+	if state := client.GetState(); state.CurrentChannel != "" || !state.UpdatedAt.CurrentChannel.IsZero() {
+		t.Errorf("expected channel to be cleared, received %q updated at %v", state.CurrentChannel, state.UpdatedAt.CurrentChannel)
+	}
+}
+
+func TestDoCanceledIsNotSent(t *testing.T) {
+	tv, client := newTestClient(t, ip.WithPollQueries())
+
+	// A slow response keeps the next command queued behind it.
+	tv.SetFaults(iptest.Faults{Delay: 300 * time.Millisecond})
+	go func() { _, _ = client.Do(context.Background(), "CURRENT_VOL") }()
+	time.Sleep(50 * time.Millisecond)
+
+	// The context has no deadline, which would also expire the command.
+	if _, err := client.Do(canceledAfter(50*time.Millisecond), "VOLUME_CONTROL 55"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, received %v", err)
+	}
+
+	// The canceled command is also dropped while waiting for the connection.
+	tv.SetState(func(s *iptest.State) { s.Power = false })
+	waitFor(t, client, func(s ip.State) bool { return !s.Connected })
+	if _, err := client.Do(canceledAfter(50*time.Millisecond), "VOLUME_MUTE on"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, received %v", err)
+	}
+	tv.SetState(func(s *iptest.State) { s.Power = true })
+	waitFor(t, client, func(s ip.State) bool { return s.Connected })
+
+	// Anything sent after the canceled commands is processed in order, so
+	// they would have been sent by now.
+	if _, err := client.Do(context.Background(), "CURRENT_VOL"); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range tv.Commands() {
+		if command == "VOLUME_CONTROL 55" || command == "VOLUME_MUTE on" {
+			t.Errorf("expected canceled command %q not to be sent", command)
+		}
+	}
+	if state := tv.State(); state.Volume != 10 || state.Muted {
+		t.Errorf("unexpected TV state: %+v", state)
+	}
+}
+
+func canceledAfter(d time.Duration) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(d, cancel)
+	return ctx
+}
+
+func TestPowerOnReconnects(t *testing.T) {
+	wol, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wol.Close()
+
+	tv, client := newTestClient(t,
+		ip.WithMACAddress("a0:b1:c2:d3:e4:01"),
+		ip.WithWOL(ip.WOLConfig{Targets: []string{wol.LocalAddr().String()}}),
+	)
+
+	// Leave the TV off long enough for the reconnection backoff to grow to
+	// several seconds.
+	tv.SetState(func(s *iptest.State) { s.Power = false })
+	waitFor(t, client, func(s ip.State) bool { return !s.Connected })
+	time.Sleep(3300 * time.Millisecond)
+
+	if err := client.PowerOn(); err != nil {
+		t.Fatal(err)
+	}
+	if power := client.GetState().Power; power != ip.PowerStateTurningOn {
+		t.Errorf("expected power state %q, received %q", ip.PowerStateTurningOn, power)
+	}
+	time.AfterFunc(500*time.Millisecond, func() {
+		tv.SetState(func(s *iptest.State) { s.Power = true })
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.WaitFor(ctx, func(s ip.State) bool { return s.Connected }); err != nil {
+		t.Fatalf("expected to reconnect soon after the TV turned on: %v", err)
+	}
+}
+
+func TestLateResponse(t *testing.T) {
+	tv, client := newTestClient(t, ip.WithPollQueries(), ip.WithReadTimeout(200*time.Millisecond))
+	ctx := context.Background()
+
+	tv.SetFaults(iptest.Faults{Delay: 300 * time.Millisecond})
+	if _, err := client.Do(ctx, "GET_MACADDRESS wired"); !errors.Is(err, ip.ErrNoResponse) {
+		t.Fatalf("expected ErrNoResponse, received %v", err)
+	}
+	// The late response is still applied to the state.
+	waitFor(t, client, func(s ip.State) bool { return s.MACAddressWired == "a0:b1:c2:d3:e4:01" })
+
+	tv.SetFaults(iptest.Faults{Delay: 300 * time.Millisecond, NG: []string{"VOLUME_CONTROL"}})
+	if _, err := client.Do(ctx, "VOLUME_CONTROL 40"); !errors.Is(err, ip.ErrNoResponse) {
+		t.Fatalf("expected ErrNoResponse, received %v", err)
+	}
+	// The late NG arrives while the next command is waiting, and must not be
+	// mistaken for its response.
+	tv.SetFaults(iptest.Faults{})
+	if resp, err := client.Do(ctx, "KEY_ACTION ok"); err != nil || resp.Value != "OK" {
+		t.Fatalf("expected OK, received %q: %v", resp.Value, err)
+	}
+}
+
+func TestSendDoesNotExpireBehindSlowResponse(t *testing.T) {
+	tv, client := newTestClient(t, ip.WithPollQueries())
+
+	tv.SetFaults(iptest.Faults{Delay: 300 * time.Millisecond})
+	go func() { _, _ = client.Do(context.Background(), "CURRENT_VOL") }()
+	time.Sleep(50 * time.Millisecond)
+
+	// The command waits in the queue for longer than Send waits to queue it.
+	if err := client.Send("VOLUME_CONTROL 55"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for tv.State().Volume != 55 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if volume := tv.State().Volume; volume != 55 {
+		t.Errorf("expected volume 55, received %d", volume)
+	}
+	if stats := client.QueueStats(); stats.Expired != 0 {
+		t.Errorf("expected no expired commands, received %+v", stats)
+	}
+}
+
+func TestCommandTTL(t *testing.T) {
+	for _, hold := range []bool{false, true} {
+		t.Run(fmt.Sprintf("hold important %v", hold), func(t *testing.T) {
+			tv, client := newTestClient(t,
+				ip.WithPollQueries(),
+				ip.WithCommandTTL(200*time.Millisecond),
+				ip.WithHoldImportant(hold),
+			)
+
+			tv.SetState(func(s *iptest.State) { s.Power = false })
+			waitFor(t, client, func(s ip.State) bool { return !s.Connected })
+
+			important := make(chan error, 1)
+			go func() {
+				_, err := client.Do(context.Background(), "VOLUME_CONTROL 20")
+				important <- err
+			}()
+			// Other commands expire regardless.
+			if _, err := client.Do(context.Background(), "KEY_ACTION ok"); !errors.Is(err, ip.ErrExpired) {
+				t.Errorf("expected ErrExpired, received %v", err)
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			tv.SetState(func(s *iptest.State) { s.Power = true })
+
+			err := <-important
+			if !hold {
+				if !errors.Is(err, ip.ErrExpired) {
+					t.Errorf("expected ErrExpired, received %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected the command to be held until the TV reconnected: %v", err)
+			}
+			if volume := tv.State().Volume; volume != 20 {
+				t.Errorf("expected volume 20, received %d", volume)
+			}
+		})
+	}
+}
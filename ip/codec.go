@@ -0,0 +1,87 @@
+package ip
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Codec converts commands into the bytes written to the device, and reads the
+// responses it sends back. *Encoder implements the encrypted protocol used by
+// most devices, and PlaintextCodec the unencrypted protocol used by older
+// models and firmware.
+type Codec interface {
+	// Encode returns the bytes written to the connection for a single
+	// command.
+	Encode(plaintext []byte) []byte
+
+	// NewDecoder returns a Decoder reading responses from r.
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Decoder reads one complete response at a time from a connection.
+type Decoder interface {
+	ReadFrame() ([]byte, error)
+}
+
+// WithCodec sets the Codec used to communicate with the device. When set, the
+// key passed to New is not used. The default is an *Encoder created with that
+// key.
+func WithCodec(codec Codec) Option {
+	return func(client *Client) {
+		client.codec = codec
+	}
+}
+
+func (e *Encoder) NewDecoder(r io.Reader) Decoder {
+	return NewFrameReader(r, e)
+}
+
+// PlaintextCodec is the unencrypted protocol, where each command and response
+// is a line of text terminated by a carriage return.
+type PlaintextCodec struct{}
+
+func (PlaintextCodec) Encode(plaintext []byte) []byte {
+	return append(slices.Clip(plaintext), '\r')
+}
+
+func (PlaintextCodec) NewDecoder(r io.Reader) Decoder {
+	return &lineReader{r: bufio.NewReader(r)}
+}
+
+// lineReader reads responses terminated by a carriage return, a line feed or
+// both.
+type lineReader struct {
+	r *bufio.Reader
+}
+
+func (lr *lineReader) ReadFrame() ([]byte, error) {
+	var line []byte
+	for {
+		b, err := lr.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if b == '\r' || b == '\n' {
+			if len(bytes.TrimSpace(line)) == 0 {
+				line = line[:0]
+				continue
+			}
+			return line, nil
+		}
+		// A device that expects encryption responds with ciphertext, which
+		// is reported like a wrong key since it cannot be recovered from.
+		if !isText([]byte{b}) {
+			return nil, fmt.Errorf("%w: response is not plain text", ErrWrongKey)
+		}
+		if len(line) >= maxFrameSize {
+			return nil, fmt.Errorf("%w: exceeded %d bytes", ErrFrameTooLarge, maxFrameSize)
+		}
+		line = append(line, b)
+	}
+}
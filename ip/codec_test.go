@@ -0,0 +1,165 @@
+package ip_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+	"go.chrisrx.dev/webos/ip/iptest"
+)
+
+func TestPlaintext(t *testing.T) {
+	tv, err := iptest.NewPlaintextServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tv.Close()
+
+	client, err := ip.New(context.Background(), tv.Addr, "", ip.WithCodec(ip.PlaintextCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	waitFor(t, client, func(s ip.State) bool { return s.Connected && s.Power == ip.PowerStateOn })
+
+	ctx := context.Background()
+	resp, err := client.Do(ctx, "CURRENT_VOL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Value != "VOL:10" {
+		t.Errorf("expected VOL:10, received %q", resp.Value)
+	}
+	if err := client.SetVolume(ctx, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ChangeInput(ctx, "hdmi2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := tv.State(); state.Volume != 30 || state.App != "com.webos.app.hdmi2" {
+		t.Errorf("unexpected TV state: %+v", state)
+	}
+	if state := client.GetState(); state.CurrentVolume != 30 || state.CurrentApp != "com.webos.app.hdmi2" {
+		t.Errorf("unexpected client state: %+v", state)
+	}
+}
+
+// waitInvalidKey waits for the client to give up connecting because the key
+// or protocol is wrong.
+func waitInvalidKey(t *testing.T, client *ip.Client) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := client.Err(); !errors.Is(err, ip.ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey, received %v: %+v", err, client.GetState())
+	}
+	if _, err := client.Do(context.Background(), "CURRENT_VOL"); !errors.Is(err, ip.ErrInvalidKey) {
+		t.Errorf("expected commands to fail with ErrInvalidKey, received %v", err)
+	}
+	if state := client.GetState(); state.Connected {
+		t.Error("expected client to be disconnected")
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("expected Close to succeed, received %v", err)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	tv, err := iptest.NewServer(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tv.Close()
+
+	client, err := ip.New(context.Background(), tv.Addr, "WXYZ9876")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	waitInvalidKey(t, client)
+}
+
+func TestPlaintextClientEncryptedServer(t *testing.T) {
+	tv, err := iptest.NewServer(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tv.Close()
+
+	// The encrypted server waits for a complete frame, so the handshake goes
+	// unanswered and the mismatch is only detected once more commands have
+	// been sent.
+	client, err := ip.New(context.Background(), tv.Addr, "",
+		ip.WithCodec(ip.PlaintextCodec{}),
+		ip.WithReadTimeout(100*time.Millisecond),
+		ip.WithPollQueries(ip.PollQuery{Command: "CURRENT_VOL", Interval: 100 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	waitInvalidKey(t, client)
+}
+
+func TestEncryptedClientPlaintextServer(t *testing.T) {
+	tv, err := iptest.NewPlaintextServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tv.Close()
+
+	client, err := ip.New(context.Background(), tv.Addr, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	waitInvalidKey(t, client)
+}
+
+func TestPlaintextClientWrongKeyResponses(t *testing.T) {
+	tv, err := iptest.NewPlaintextServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tv.Close()
+	tv.SetFaults(iptest.Faults{WrongKey: true})
+
+	client, err := ip.New(context.Background(), tv.Addr, "", ip.WithCodec(ip.PlaintextCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	waitInvalidKey(t, client)
+}
+
+// A response that cannot be decoded once the key is known to work only means
+// the stream is out of sync, and the client reconnects.
+func TestUndecodableResponseAfterHandshake(t *testing.T) {
+	tv, client := newTestClient(t, ip.WithPollQueries(), ip.WithReadTimeout(200*time.Millisecond))
+	ctx := context.Background()
+
+	// The fake TV encodes the app as-is, which isn't valid plain text.
+	tv.SetState(func(s *iptest.State) { s.App = "café" })
+	if _, err := client.Do(ctx, "CURRENT_APP"); err == nil {
+		t.Fatal("expected error")
+	}
+	tv.SetState(func(s *iptest.State) { s.App = "com.webos.app.hdmi1" })
+
+	waitFor(t, client, func(s ip.State) bool { return s.Connected })
+	if err := client.Err(); err != nil {
+		t.Fatalf("expected client to keep connecting, received %v", err)
+	}
+	if resp, err := client.Do(ctx, "CURRENT_APP"); err != nil || resp.Value != "APP:com.webos.app.hdmi1" {
+		t.Errorf("expected APP:com.webos.app.hdmi1, received %q: %v", resp.Value, err)
+	}
+}
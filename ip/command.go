@@ -0,0 +1,226 @@
+package ip
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrNoResponse is returned when the device accepted the command but did
+	// not reply before the read deadline.
+	ErrNoResponse = errors.New("no response from device")
+
+	// ErrNG is returned when the device explicitly rejected the command.
+	ErrNG = errors.New("device responded NG")
+
+	// ErrInvalidCommand is returned when a command fails validation and is
+	// never sent to the device.
+	ErrInvalidCommand = errors.New("invalid command")
+
+	// ErrExpired is returned when a command was still queued, usually while
+	// waiting for the connection, when its deadline passed.
+	ErrExpired = errors.New("command expired before it was sent")
+)
+
+// CommandError describes a command that did not complete successfully.
+type CommandError struct {
+	Command  string
+	Response string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	if e.Response != "" {
+		return fmt.Sprintf("%q: %v (response: %q)", e.Command, e.Err, e.Response)
+	}
+	return fmt.Sprintf("%q: %v", e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Response is the reply returned by the device for a single command.
+type Response struct {
+	Command string
+	Value   string
+}
+
+// OK reports whether the device acknowledged the command with "OK".
+func (r Response) OK() bool {
+	return r.Value == "OK"
+}
+
+type result struct {
+	resp Response
+	err  error
+}
+
+type request struct {
+	command  string
+	priority Priority
+
+	// deadline is when the command expires if it hasn't been sent yet. A
+	// zero deadline means the command never expires.
+	deadline time.Time
+
+	// waiters receive the result of the command. It is empty for commands
+	// that are sent without waiting for a response (i.e. Send and MustSend),
+	// and can have several entries when duplicate polls are coalesced.
+	waiters []chan result
+
+	// canceled is set when the caller stops waiting for the result, in
+	// which case the command is no longer sent.
+	canceled atomic.Bool
+}
+
+func newRequest(command string, priority Priority, deadline time.Time, wait bool) *request {
+	r := &request{command: command, priority: priority, deadline: deadline}
+	if wait {
+		r.waiters = append(r.waiters, make(chan result, 1))
+	}
+	return r
+}
+
+func (r *request) expired(now time.Time) bool {
+	return !r.deadline.IsZero() && now.After(r.deadline)
+}
+
+func (r *request) cancel() {
+	r.canceled.Store(true)
+}
+
+func (r *request) done(resp Response, err error) {
+	for _, ch := range r.waiters {
+		ch <- result{resp: resp, err: err}
+	}
+}
+
+type commandSpec struct {
+	// args is the exact number of arguments the command accepts.
+	args int
+
+	// valid optionally restricts the value of the argument.
+	valid func(arg string) bool
+
+	// important commands are held while disconnected when the client is
+	// configured with WithHoldImportant.
+	important bool
+
+	// response reports whether a response has the shape expected for this
+	// command. When nil, the command is expected to respond with OK.
+	response func(resp string) bool
+}
+
+// expects reports whether resp could be the response to command. Any command
+// can be rejected with NG.
+func expects(command, resp string) bool {
+	if resp == "NG" {
+		return true
+	}
+	verb, _, _ := strings.Cut(command, " ")
+	spec, ok := commands[verb]
+	if !ok || spec.response == nil {
+		return resp == "OK"
+	}
+	return spec.response(resp)
+}
+
+// commands is the allowlist of command verbs that can be sent to the device.
+var commands = map[string]commandSpec{
+	"KEY_ACTION":          {args: 1, valid: func(arg string) bool { return Key(arg).Valid() }},
+	"INPUT_SELECT":        {args: 1, valid: func(arg string) bool { return slices.Contains(inputSources, arg) }, important: true},
+	"APP_LAUNCH":          {args: 1, important: true},
+	"POWER":               {args: 1, valid: oneOf("off")},
+	"VOLUME_CONTROL":      {args: 1, valid: intRange(MinVolume, MaxVolume), important: true},
+	"VOLUME_MUTE":         {args: 1, valid: oneOf("on", "off"), important: true},
+	"CURRENT_VOL":         {response: hasPrefix("VOL:")},
+	"MUTE_STATE":          {response: hasPrefix("MUTE:")},
+	"CURRENT_APP":         {response: hasPrefix("APP:")},
+	"GET_MACADDRESS":      {args: 1, valid: oneOf("wired", "wifi"), response: isMACAddress},
+	"GET_IPCONTROL_STATE": {response: oneOfFold("on", "off")},
+	"PICTURE_MODE":        {args: 1, valid: func(arg string) bool { return PictureMode(arg).Valid() }},
+	"ENERGY_SAVING":       {args: 1, valid: func(arg string) bool { return EnergySaving(arg).Valid() }},
+	"ASPECT_RATIO":        {args: 1, valid: func(arg string) bool { return AspectRatio(arg).Valid() }},
+	"PICTURE_BACKLIGHT":   {args: 1, valid: intRange(MinBacklight, MaxBacklight)},
+	"SCREEN_MUTE":         {args: 1, valid: oneOf(string(ScreenMuteOn), string(ScreenMuteVideo), string(ScreenMuteOff))},
+}
+
+// argPattern matches the characters allowed in any command argument. Most
+// importantly, this excludes whitespace and control characters, since the
+// device treats a carriage return as the end of a command.
+var argPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// ValidateCommand checks that the command uses an allowed verb and that its
+// arguments cannot be interpreted as additional protocol tokens or commands.
+func ValidateCommand(command string) error {
+	if strings.ContainsFunc(command, func(r rune) bool {
+		return r < 0x20 || r == 0x7f
+	}) {
+		return fmt.Errorf("%w: %q: contains control characters", ErrInvalidCommand, command)
+	}
+	verb, args, found := strings.Cut(command, " ")
+	spec, ok := commands[verb]
+	if !ok {
+		return fmt.Errorf("%w: %q: unknown command verb", ErrInvalidCommand, command)
+	}
+	var fields []string
+	if found {
+		fields = strings.Split(args, " ")
+	}
+	if len(fields) != spec.args {
+		return fmt.Errorf("%w: %q: expected %d argument(s), received %d", ErrInvalidCommand, command, spec.args, len(fields))
+	}
+	for _, arg := range fields {
+		if !argPattern.MatchString(arg) {
+			return fmt.Errorf("%w: %q: invalid argument %q", ErrInvalidCommand, command, arg)
+		}
+		if spec.valid != nil && !spec.valid(arg) {
+			return fmt.Errorf("%w: %q: unsupported argument %q", ErrInvalidCommand, command, arg)
+		}
+	}
+	return nil
+}
+
+func oneOf(values ...string) func(string) bool {
+	return func(arg string) bool {
+		return slices.Contains(values, arg)
+	}
+}
+
+func intRange(lo, hi int) func(string) bool {
+	return func(arg string) bool {
+		i, err := strconv.Atoi(arg)
+		return err == nil && i >= lo && i <= hi
+	}
+}
+
+func oneOfFold(values ...string) func(string) bool {
+	return func(arg string) bool {
+		return slices.ContainsFunc(values, func(v string) bool {
+			return strings.EqualFold(v, arg)
+		})
+	}
+}
+
+func hasPrefix(prefix string) func(string) bool {
+	return func(resp string) bool {
+		return strings.HasPrefix(resp, prefix)
+	}
+}
+
+func isMACAddress(resp string) bool {
+	_, err := ParseMAC(resp)
+	return err == nil
+}
+
+func isImportant(command string) bool {
+	verb, _, _ := strings.Cut(command, " ")
+	return commands[verb].important
+}
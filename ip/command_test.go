@@ -0,0 +1,51 @@
+package ip
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateCommand(t *testing.T) {
+	cases := []struct {
+		command string
+		valid   bool
+	}{
+		{"KEY_ACTION ok", true},
+		{"POWER off", true},
+		{"CURRENT_VOL", true},
+		{"VOLUME_CONTROL 0", true},
+		{"VOLUME_CONTROL 100", true},
+		{"INPUT_SELECT hdmi1", true},
+		{"APP_LAUNCH com.webos.app.hdmi1", true},
+		{"PICTURE_BACKLIGHT 50", true},
+
+		{"KEY_ACTION ok\rPOWER off", false},
+		{"KEY_ACTION ok\nPOWER off", false},
+		{"KEY_ACTION ok POWER", false},
+		{"KEY_ACTION ok ", false},
+		{"KEY_ACTION ", false},
+		{"KEY_ACTION  ok", false},
+		{"KEY_ACTION ok\x7f", false},
+		{"KEY_ACTION\tok", false},
+		{"KEY_ACTION notakey", false},
+		{"KEY_ACTION", false},
+		{"CURRENT_VOL now", false},
+		{"POWER on", false},
+		{"VOLUME_CONTROL 101", false},
+		{"VOLUME_CONTROL -1", false},
+		{"INPUT_SELECT hdmi9", false},
+		{"APP_LAUNCH com.webos;reboot", false},
+		{"FACTORY_RESET", false},
+		{"key_action ok", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		err := ValidateCommand(tc.command)
+		if tc.valid && err != nil {
+			t.Errorf("ValidateCommand(%q): unexpected error: %v", tc.command, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrInvalidCommand) {
+			t.Errorf("ValidateCommand(%q): expected ErrInvalidCommand, received %v", tc.command, err)
+		}
+	}
+}
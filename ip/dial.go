@@ -0,0 +1,78 @@
+package ip
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+const (
+	defaultDialTimeout  = 1 * time.Second
+	defaultReadTimeout  = 1 * time.Second
+	defaultWriteTimeout = 1 * time.Second
+)
+
+// Dialer establishes the connection to the device. *net.Dialer satisfies
+// this interface, as do most proxy and tunnel dialers.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialerFunc adapts an ordinary function to a Dialer, for example to connect
+// through an SSH tunnel or to return one end of a net.Pipe.
+type DialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f DialerFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// WithDialer sets the Dialer used to connect to the device. The default is a
+// plain TCP dialer.
+func WithDialer(d Dialer) Option {
+	return func(client *Client) {
+		client.dialer = d
+	}
+}
+
+// WithDialTimeout sets how long each connection attempt can take.
+func WithDialTimeout(d time.Duration) Option {
+	return func(client *Client) {
+		client.dialTimeout = d
+	}
+}
+
+// WithReadTimeout sets how long to wait for the device to respond to a
+// command before it fails with ErrNoResponse.
+func WithReadTimeout(d time.Duration) Option {
+	return func(client *Client) {
+		client.readTimeout = d
+	}
+}
+
+// WithWriteTimeout sets how long writing a single command to the connection
+// can take. Higher latency links, such as a VPN, may need more than the
+// default.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(client *Client) {
+		client.writeTimeout = d
+	}
+}
+
+// dial connects to the device using the configured Dialer, enabling TCP
+// keepalive if the connection supports it.
+func (c *Client) dial() (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.dialTimeout)
+	defer cancel()
+
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if ka, ok := conn.(interface{ SetKeepAlive(bool) error }); ok {
+		if err := ka.SetKeepAlive(true); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
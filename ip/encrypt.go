@@ -6,7 +6,10 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
+	"slices"
 
 	"golang.org/x/crypto/pbkdf2"
 
@@ -33,7 +36,7 @@ func NewEncoder(key string) (*Encoder, error) {
 }
 
 func (e *Encoder) Encode(plaintext []byte) []byte {
-	plaintext = pad(append(plaintext, '\r'), e.b.BlockSize())
+	plaintext = pad(append(slices.Clip(plaintext), '\r'), e.b.BlockSize())
 	ciphertext := make([]byte, len(plaintext))
 	iv := make([]byte, aes.BlockSize)
 	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
@@ -45,14 +48,45 @@ func (e *Encoder) Encode(plaintext []byte) []byte {
 	return append(ivEnc, ciphertext...)
 }
 
+var (
+	// ErrShortFrame is returned when the data is too short to contain both
+	// the IV and at least one block of ciphertext.
+	ErrShortFrame = errors.New("frame too short")
+
+	// ErrMisaligned is returned when the data is not a multiple of the block
+	// size.
+	ErrMisaligned = errors.New("frame not aligned to block size")
+
+	// ErrBadPadding is returned when the decrypted data does not end with
+	// valid PKCS#7 padding. This is usually caused by the wrong key or a
+	// corrupted frame.
+	ErrBadPadding = errors.New("invalid padding")
+
+	// ErrWrongKey is returned when the decrypted data is well-formed but is
+	// not text, which most likely means it was encrypted with another key.
+	ErrWrongKey = errors.New("invalid plaintext, key is most likely wrong")
+)
+
 func (e *Encoder) Decode(ciphertext []byte) ([]byte, error) {
-	iv := make([]byte, aes.BlockSize)
-	internal.NewECBDecrypter(e.b).CryptBlocks(iv, ciphertext[:e.b.BlockSize()])
-	ciphertext = ciphertext[16:]
-	mode := cipher.NewCBCDecrypter(e.b, iv)
-	mode.CryptBlocks(ciphertext, ciphertext)
-	ciphertext = trim(ciphertext)
-	return ciphertext, nil
+	bs := e.b.BlockSize()
+	if len(ciphertext) < 2*bs {
+		return nil, fmt.Errorf("%w: %d bytes", ErrShortFrame, len(ciphertext))
+	}
+	if len(ciphertext)%bs != 0 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrMisaligned, len(ciphertext))
+	}
+	iv := make([]byte, bs)
+	internal.NewECBDecrypter(e.b).CryptBlocks(iv, ciphertext[:bs])
+	plaintext := make([]byte, len(ciphertext)-bs)
+	cipher.NewCBCDecrypter(e.b, iv).CryptBlocks(plaintext, ciphertext[bs:])
+	plaintext, err := trim(plaintext, bs)
+	if err != nil {
+		return nil, err
+	}
+	if !isText(plaintext) {
+		return nil, ErrWrongKey
+	}
+	return plaintext, nil
 }
 
 func pad(data []byte, blockSize int) []byte {
@@ -61,10 +95,30 @@ func pad(data []byte, blockSize int) []byte {
 	return append(data, padtext...)
 }
 
-func trim(data []byte) []byte {
-	padding := data[len(data)-1]
-	if len(data)-int(padding) < 0 {
-		return data
+// trim removes PKCS#7 padding, validating every padding byte.
+func trim(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrBadPadding
+	}
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > blockSize || padding > len(data) {
+		return nil, fmt.Errorf("%w: length %d", ErrBadPadding, padding)
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, ErrBadPadding
+		}
+	}
+	return data[:len(data)-padding], nil
+}
+
+func isText(data []byte) bool {
+	for _, b := range data {
+		switch {
+		case b == '\r', b == '\n', b == '\t':
+		case b < 0x20, b > 0x7e:
+			return false
+		}
 	}
-	return data[:len(data)-int(padding)]
+	return true
 }
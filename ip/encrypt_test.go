@@ -0,0 +1,142 @@
+package ip
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+
+	"go.chrisrx.dev/webos/ip/internal"
+)
+
+const testKey = "ABCD1234"
+
+func newTestEncoder(t testing.TB, key string) *Encoder {
+	t.Helper()
+
+	enc, err := NewEncoder(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+// encryptRaw encrypts data as-is, without the terminator and padding added by
+// Encode, so that malformed frames can be constructed. The data must be a
+// multiple of the block size.
+func encryptRaw(e *Encoder, data []byte) []byte {
+	iv := bytes.Repeat([]byte{0x42}, aes.BlockSize)
+	frame := make([]byte, aes.BlockSize+len(data))
+	internal.NewECBEncrypter(e.b).CryptBlocks(frame[:aes.BlockSize], iv)
+	cipher.NewCBCEncrypter(e.b, iv).CryptBlocks(frame[aes.BlockSize:], data)
+	return frame
+}
+
+func TestEncodeDecode(t *testing.T) {
+	enc := newTestEncoder(t, testKey)
+	for _, s := range []string{"", "OK", "VOL:10", "APP:com.webos.app.hdmi1", "exactly 15 byte", "exactly 16 bytes"} {
+		frame := enc.Encode([]byte(s))
+		if len(frame)%aes.BlockSize != 0 {
+			t.Errorf("%q: frame of %d bytes is not block aligned", s, len(frame))
+		}
+		plaintext, err := enc.Decode(frame)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if string(plaintext) != s+"\r" {
+			t.Errorf("expected %q, received %q", s+"\r", plaintext)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	enc := newTestEncoder(t, testKey)
+	block := func(b ...byte) []byte {
+		return append(bytes.Repeat([]byte{'a'}, aes.BlockSize-len(b)), b...)
+	}
+
+	cases := []struct {
+		name     string
+		frame    []byte
+		expected error
+	}{
+		{"empty", nil, ErrShortFrame},
+		{"iv only", make([]byte, aes.BlockSize), ErrShortFrame},
+		{"partial block", make([]byte, aes.BlockSize+1), ErrShortFrame},
+		{"misaligned", make([]byte, 2*aes.BlockSize+1), ErrMisaligned},
+		{"misaligned by one block", make([]byte, 3*aes.BlockSize-1), ErrMisaligned},
+		{"zero padding", encryptRaw(enc, block(0)), ErrBadPadding},
+		{"padding larger than block", encryptRaw(enc, block(aes.BlockSize+1)), ErrBadPadding},
+		{"inconsistent padding", encryptRaw(enc, block(2, 1, 3)), ErrBadPadding},
+		{"binary plaintext", encryptRaw(enc, pad([]byte{0x00, 0x01, 0x02, '\r'}, aes.BlockSize)), ErrWrongKey},
+		{"high bytes", encryptRaw(enc, pad([]byte("VOL:\xff\r"), aes.BlockSize)), ErrWrongKey},
+	}
+	for _, tc := range cases {
+		if _, err := enc.Decode(tc.frame); !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected %v, received %v", tc.name, tc.expected, err)
+		}
+	}
+}
+
+func TestDecodeWrongKey(t *testing.T) {
+	enc := newTestEncoder(t, testKey)
+	other := newTestEncoder(t, testKey+"-wrong")
+
+	for range 32 {
+		_, err := other.Decode(enc.Encode([]byte("VOL:10")))
+		if !errors.Is(err, ErrBadPadding) && !errors.Is(err, ErrWrongKey) {
+			t.Fatalf("expected ErrBadPadding or ErrWrongKey, received %v", err)
+		}
+	}
+}
+
+func FuzzEncodeDecode(f *testing.F) {
+	for _, s := range []string{"", "OK", "NG", "VOL:10", "MUTE:on", "APP:com.webos.app.hdmi1", "a0:b1:c2:d3:e4:01", "\x00\x01", "\t\t\t\t\t\t\t\t\t"} {
+		f.Add([]byte(s))
+	}
+	enc := newTestEncoder(f, testKey)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		plaintext, err := enc.Decode(enc.Encode(data))
+		if !isText(data) {
+			if !errors.Is(err, ErrWrongKey) {
+				t.Fatalf("expected ErrWrongKey for %q, received %v", data, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", data, err)
+		}
+		if expected := append(bytes.Clone(data), '\r'); !bytes.Equal(plaintext, expected) {
+			t.Fatalf("expected %q, received %q", expected, plaintext)
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	enc := newTestEncoder(f, testKey)
+	f.Add(enc.Encode([]byte("OK")))
+	f.Add(enc.Encode([]byte("APP:com.webos.app.hdmi1")))
+	f.Add(append(enc.Encode([]byte("OK")), enc.Encode([]byte("VOL:10"))...))
+	f.Add([]byte{})
+	f.Add(make([]byte, aes.BlockSize))
+	f.Add(make([]byte, 2*aes.BlockSize))
+	f.Add(make([]byte, 2*aes.BlockSize+1))
+	f.Add([]byte("OK\r"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if plaintext, err := enc.Decode(data); err == nil && !isText(plaintext) {
+			t.Fatalf("decoded plaintext %q is not text", plaintext)
+		}
+		fr := NewFrameReader(bytes.NewReader(data), enc)
+		for {
+			plaintext, err := fr.ReadFrame()
+			if err != nil {
+				break
+			}
+			if !isText(plaintext) {
+				t.Fatalf("read plaintext %q is not text", plaintext)
+			}
+		}
+	})
+}
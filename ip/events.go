@@ -0,0 +1,203 @@
+package ip
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	VolumeChanged      EventType = "volume_changed"
+	MuteChanged        EventType = "mute_changed"
+	AppChanged         EventType = "app_changed"
+	ChannelChanged     EventType = "channel_changed"
+	PowerChanged       EventType = "power_changed"
+	PictureChanged     EventType = "picture_changed"
+	ScreenChanged      EventType = "screen_changed"
+	ConnectionLost     EventType = "connection_lost"
+	ConnectionRestored EventType = "connection_restored"
+)
+
+// StateEvent describes a single change in device state. Previous and Current
+// are snapshots of the full state on either side of the change.
+type StateEvent struct {
+	Type     EventType
+	Time     time.Time
+	Previous State
+	Current  State
+}
+
+// subscriberBufferSize is the number of events buffered for each subscriber
+// before the oldest events start being discarded.
+const subscriberBufferSize = 16
+
+type subscribers struct {
+	mu     sync.Mutex
+	subs   map[chan StateEvent]struct{}
+	closed bool
+}
+
+// close prevents any new subscriptions. Existing subscriptions are closed by
+// their own goroutines once the client context is done.
+func (s *subscribers) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+}
+
+// Subscribe returns a channel that receives an event each time the device
+// state changes. The channel is closed once ctx is canceled or the client is
+// closed. Events are never blocked on slow consumers: if a subscriber falls
+// behind, the oldest undelivered events are dropped in favor of newer ones.
+func (c *Client) Subscribe(ctx context.Context) <-chan StateEvent {
+	ch := make(chan StateEvent, subscriberBufferSize)
+
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	if c.subs.closed {
+		close(ch)
+		return ch
+	}
+	if c.subs.subs == nil {
+		c.subs.subs = make(map[chan StateEvent]struct{})
+	}
+	c.subs.subs[ch] = struct{}{}
+
+	c.goroutine(func() {
+		select {
+		case <-ctx.Done():
+		case <-c.ctx.Done():
+		}
+		c.subs.mu.Lock()
+		defer c.subs.mu.Unlock()
+		delete(c.subs.subs, ch)
+		close(ch)
+	})
+	return ch
+}
+
+func (c *Client) publish(ev StateEvent) {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+
+	for ch := range c.subs.subs {
+		select {
+		case ch <- ev:
+			continue
+		default:
+		}
+		// The subscriber is not keeping up, so the oldest event is discarded
+		// to make room for the latest one.
+		select {
+		case <-ch:
+			c.logger.Warn("dropped event for slow subscriber", slog.String("type", string(ev.Type)))
+		default:
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// updateState applies fn to the device state and publishes an event for each
+// field that changed as a result.
+func (c *Client) updateState(fn func(*State)) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	prev := c.state
+	fn(&c.state)
+	next := c.state
+
+	// Anything waiting on the state is woken to check it again.
+	close(c.changed)
+	c.changed = make(chan struct{})
+
+	now := time.Now()
+	if prev.CurrentVolume != next.CurrentVolume {
+		c.publish(StateEvent{Type: VolumeChanged, Time: now, Previous: prev, Current: next})
+	}
+	if prev.MuteState != next.MuteState {
+		c.publish(StateEvent{Type: MuteChanged, Time: now, Previous: prev, Current: next})
+	}
+	if prev.CurrentApp != next.CurrentApp {
+		c.publish(StateEvent{Type: AppChanged, Time: now, Previous: prev, Current: next})
+	}
+	if prev.CurrentChannel != next.CurrentChannel {
+		c.publish(StateEvent{Type: ChannelChanged, Time: now, Previous: prev, Current: next})
+	}
+	if prev.Power != next.Power {
+		c.publish(StateEvent{Type: PowerChanged, Time: now, Previous: prev, Current: next})
+	}
+	if prev.PictureMode != next.PictureMode || prev.EnergySaving != next.EnergySaving ||
+		prev.AspectRatio != next.AspectRatio || prev.Backlight != next.Backlight {
+		c.publish(StateEvent{Type: PictureChanged, Time: now, Previous: prev, Current: next})
+	}
+	if prev.ScreenMuted != next.ScreenMuted {
+		c.publish(StateEvent{Type: ScreenChanged, Time: now, Previous: prev, Current: next})
+	}
+	if prev.Connected != next.Connected {
+		typ := ConnectionLost
+		if next.Connected {
+			typ = ConnectionRestored
+		}
+		c.publish(StateEvent{Type: typ, Time: now, Previous: prev, Current: next})
+	}
+}
+
+// setConnected records whether the device is currently connected.
+func (c *Client) setConnected(connected bool) {
+	c.connected.Store(connected)
+
+	c.readyMu.Lock()
+	select {
+	case <-c.ready:
+		if !connected {
+			c.ready = make(chan struct{})
+		}
+	default:
+		if connected {
+			close(c.ready)
+		}
+	}
+	c.readyMu.Unlock()
+
+	c.updateState(func(s *State) {
+		s.Connected = connected
+		s.UpdatedAt.Connected = time.Now()
+	})
+	c.transitionPower(func(current PowerState) PowerState {
+		if connected {
+			return PowerStateOn
+		}
+		switch current {
+		case PowerStateStandby, PowerStateTurningOn:
+			// Losing the connection is expected in both of these states.
+			return current
+		default:
+			return PowerStateUnknown
+		}
+	})
+}
+
+// connectedCh returns a channel that is closed while the device is connected.
+func (c *Client) connectedCh() <-chan struct{} {
+	c.readyMu.Lock()
+	defer c.readyMu.Unlock()
+
+	return c.ready
+}
+
+// setError records the most recent error communicating with the device.
+func (c *Client) setError(err error) {
+	c.updateState(func(s *State) {
+		s.LastError = err.Error()
+		s.UpdatedAt.LastError = time.Now()
+	})
+}
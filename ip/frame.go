@@ -0,0 +1,75 @@
+package ip
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.chrisrx.dev/webos/ip/internal"
+)
+
+// maxFrameSize limits how much data is read while searching for the end of
+// a frame, so that a corrupted stream cannot cause unbounded reads.
+const maxFrameSize = 64 * 1024
+
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// FrameReader reads encrypted frames from a stream.
+//
+// Frames are not length-prefixed, so a single read may return part of a
+// frame or several coalesced frames. Since frames are CBC encrypted, the
+// reader decrypts one block at a time and the frame ends at the first block
+// that ends with valid PKCS#7 padding.
+type FrameReader struct {
+	r   *bufio.Reader
+	enc *Encoder
+}
+
+func NewFrameReader(r io.Reader, enc *Encoder) *FrameReader {
+	return &FrameReader{
+		r:   bufio.NewReader(r),
+		enc: enc,
+	}
+}
+
+// ReadFrame reads the next complete frame and returns its decrypted
+// plaintext.
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	bs := fr.enc.b.BlockSize()
+	frame := make([]byte, bs, 4*bs)
+	if n, err := io.ReadFull(fr.r, frame); err != nil {
+		// A device that doesn't use encryption responds with a short line of
+		// text, which can never be decrypted.
+		if n > 0 && isText(frame[:n]) && bytes.ContainsAny(frame[:n], "\r\n") {
+			return nil, fmt.Errorf("%w: response %q is plain text", ErrWrongKey, bytes.TrimSpace(frame[:n]))
+		}
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	internal.NewECBDecrypter(fr.enc.b).CryptBlocks(iv, frame)
+	mode := cipher.NewCBCDecrypter(fr.enc.b, iv)
+
+	block := make([]byte, bs)
+	for len(frame) < maxFrameSize {
+		frame = append(frame, make([]byte, bs)...)
+		ciphertext := frame[len(frame)-bs:]
+		if _, err := io.ReadFull(fr.r, ciphertext); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		mode.CryptBlocks(block, ciphertext)
+		if _, err := trim(block, bs); err == nil {
+			return fr.enc.Decode(frame)
+		}
+		if !isText(block) {
+			return nil, fmt.Errorf("%w: cannot find end of frame", ErrWrongKey)
+		}
+	}
+	return nil, fmt.Errorf("%w: exceeded %d bytes", ErrFrameTooLarge, maxFrameSize)
+}
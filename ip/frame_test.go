@@ -0,0 +1,67 @@
+package ip
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFrameReader(t *testing.T) {
+	enc := newTestEncoder(t, testKey)
+	long := "APP:" + strings.Repeat("com.webos.app.", 250)
+	responses := []string{"OK", "VOL:10", "exactly 15 byte", long, "MUTE:on"}
+
+	var stream []byte
+	for _, resp := range responses {
+		stream = append(stream, enc.Encode([]byte(resp))...)
+	}
+
+	for _, tc := range []struct {
+		name string
+		r    io.Reader
+	}{
+		{"coalesced", bytes.NewReader(stream)},
+		{"one byte at a time", iotest.OneByteReader(bytes.NewReader(stream))},
+		{"half reads", iotest.HalfReader(bytes.NewReader(stream))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fr := NewFrameReader(tc.r, enc)
+			for _, expected := range responses {
+				plaintext, err := fr.ReadFrame()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(plaintext) != expected+"\r" {
+					t.Errorf("expected %q, received %q", expected+"\r", plaintext)
+				}
+			}
+			if _, err := fr.ReadFrame(); !errors.Is(err, io.EOF) {
+				t.Errorf("expected io.EOF, received %v", err)
+			}
+		})
+	}
+}
+
+func TestFrameReaderErrors(t *testing.T) {
+	enc := newTestEncoder(t, testKey)
+	frame := enc.Encode([]byte("VOL:10"))
+
+	cases := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"truncated", frame[:len(frame)-1], io.ErrUnexpectedEOF},
+		{"plain text", []byte("OK\r"), ErrWrongKey},
+		{"wrong key", newTestEncoder(t, testKey+"-wrong").Encode([]byte(strings.Repeat("VOL:10", 8))), ErrWrongKey},
+	}
+	for _, tc := range cases {
+		fr := NewFrameReader(bytes.NewReader(tc.data), enc)
+		if _, err := fr.ReadFrame(); !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected %v, received %v", tc.name, tc.expected, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package ip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// inputSources are the arguments accepted by the INPUT_SELECT command.
+var inputSources = []string{
+	"hdmi1",
+	"hdmi2",
+	"hdmi3",
+	"hdmi4",
+	"atv",
+	"dtv",
+	"av1",
+	"component1",
+}
+
+// Input is an entry in the catalog of inputs and apps that can be selected
+// with ChangeInput. Exactly one of Input or AppID must be set.
+type Input struct {
+	// Name is what the input is selected by, along with any Aliases. Names
+	// are matched case insensitively.
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Input is the physical input selected with INPUT_SELECT (e.g. "hdmi1").
+	Input string `json:"input,omitempty"`
+
+	// AppID is the app started with APP_LAUNCH (e.g. "youtube.leanback.v4").
+	AppID string `json:"app_id,omitempty"`
+
+	// ExpectedApp is the CURRENT_APP value reported by the device once the
+	// input is selected. When empty, it defaults to the AppID for apps, or
+	// "com.webos.app.<input>" for physical inputs.
+	ExpectedApp string `json:"expected_app,omitempty"`
+}
+
+// Command returns the command that selects the input.
+func (in Input) Command() string {
+	if in.AppID != "" {
+		return fmt.Sprintf("APP_LAUNCH %s", in.AppID)
+	}
+	return fmt.Sprintf("INPUT_SELECT %s", in.Input)
+}
+
+// Expected returns the CURRENT_APP value that confirms the input is selected.
+func (in Input) Expected() string {
+	switch {
+	case in.ExpectedApp != "":
+		return in.ExpectedApp
+	case in.AppID != "":
+		return in.AppID
+	default:
+		return "com.webos.app." + in.Input
+	}
+}
+
+func (in Input) matches(name string) bool {
+	return strings.EqualFold(in.Name, name) || slices.ContainsFunc(in.Aliases, func(alias string) bool {
+		return strings.EqualFold(alias, name)
+	})
+}
+
+// DefaultInputs is the catalog used when none is provided with WithInputs.
+var DefaultInputs = Inputs{
+	{Name: "hdmi1", Input: "hdmi1"},
+	{Name: "hdmi2", Input: "hdmi2"},
+	{Name: "hdmi3", Input: "hdmi3"},
+	{Name: "hdmi4", Input: "hdmi4"},
+	{Name: "atv", Input: "atv", ExpectedApp: "com.webos.app.livetv"},
+	{Name: "dtv", Input: "dtv", ExpectedApp: "com.webos.app.livetv"},
+	{Name: "av1", Input: "av1", ExpectedApp: "com.webos.app.externalinput.av1"},
+	{Name: "component1", Input: "component1", ExpectedApp: "com.webos.app.externalinput.component"},
+	{Name: "youtube", AppID: "youtube.leanback.v4"},
+}
+
+// Inputs is a catalog of inputs and apps.
+type Inputs []Input
+
+var ErrUnknownInput = errors.New("unknown input")
+
+// Lookup returns the input with the provided name or alias.
+func (inputs Inputs) Lookup(name string) (Input, error) {
+	name = strings.TrimSpace(name)
+	for _, in := range inputs {
+		if in.matches(name) {
+			return in, nil
+		}
+	}
+	return Input{}, fmt.Errorf("%w: %q", ErrUnknownInput, name)
+}
+
+// Validate checks that every entry can be selected and that no name or alias
+// is used more than once.
+func (inputs Inputs) Validate() error {
+	seen := make(map[string]bool)
+	for i, in := range inputs {
+		if in.Name == "" {
+			return fmt.Errorf("input %d: missing name", i)
+		}
+		if (in.Input == "") == (in.AppID == "") {
+			return fmt.Errorf("input %q: exactly one of input or app_id must be set", in.Name)
+		}
+		if err := ValidateCommand(in.Command()); err != nil {
+			return fmt.Errorf("input %q: %w", in.Name, err)
+		}
+		for _, name := range append([]string{in.Name}, in.Aliases...) {
+			key := strings.ToLower(name)
+			if seen[key] {
+				return fmt.Errorf("input %q: duplicate name or alias %q", in.Name, name)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+// LoadInputs reads a catalog from a JSON array of inputs.
+func LoadInputs(r io.Reader) (Inputs, error) {
+	var inputs Inputs
+	if err := json.NewDecoder(r).Decode(&inputs); err != nil {
+		return nil, fmt.Errorf("cannot decode inputs: %w", err)
+	}
+	if err := inputs.Validate(); err != nil {
+		return nil, err
+	}
+	return inputs, nil
+}
+
+// LoadInputsFile reads a catalog from a JSON file.
+func LoadInputsFile(path string) (Inputs, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadInputs(f)
+}
+
+// WithInputs replaces the catalog of inputs used by ChangeInput.
+func WithInputs(inputs Inputs) Option {
+	return func(client *Client) {
+		client.inputs = inputs
+	}
+}
+
+// Inputs returns the catalog of inputs that can be selected with
+// ChangeInput.
+func (c *Client) Inputs() Inputs {
+	return slices.Clone(c.inputs)
+}
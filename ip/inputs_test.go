@@ -0,0 +1,125 @@
+package ip
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testInputs = Inputs{
+	{Name: "hdmi1", Aliases: []string{"Apple TV", "atv4k"}, Input: "hdmi1"},
+	{Name: "Live TV", Input: "dtv", ExpectedApp: "com.webos.app.livetv"},
+	{Name: "youtube", Aliases: []string{"yt"}, AppID: "youtube.leanback.v4"},
+}
+
+func TestInputsLookup(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+		command  string
+		app      string
+	}{
+		{"hdmi1", "hdmi1", "INPUT_SELECT hdmi1", "com.webos.app.hdmi1"},
+		{"HDMI1", "hdmi1", "INPUT_SELECT hdmi1", "com.webos.app.hdmi1"},
+		{"apple tv", "hdmi1", "INPUT_SELECT hdmi1", "com.webos.app.hdmi1"},
+		{" ATV4K ", "hdmi1", "INPUT_SELECT hdmi1", "com.webos.app.hdmi1"},
+		{"live tv", "Live TV", "INPUT_SELECT dtv", "com.webos.app.livetv"},
+		{"YT", "youtube", "APP_LAUNCH youtube.leanback.v4", "youtube.leanback.v4"},
+		{"hdmi2", "", "", ""},
+		{"apple", "", "", ""},
+		{"", "", "", ""},
+	}
+	for _, tc := range cases {
+		in, err := testInputs.Lookup(tc.name)
+		if tc.expected == "" {
+			if !errors.Is(err, ErrUnknownInput) {
+				t.Errorf("Lookup(%q): expected ErrUnknownInput, received %v", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", tc.name, err)
+			continue
+		}
+		if in.Name != tc.expected || in.Command() != tc.command || in.Expected() != tc.app {
+			t.Errorf("Lookup(%q): expected %s (%q, %q), received %s (%q, %q)",
+				tc.name, tc.expected, tc.command, tc.app, in.Name, in.Command(), in.Expected())
+		}
+	}
+}
+
+func TestInputsValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		inputs Inputs
+		valid  bool
+	}{
+		{"default", DefaultInputs, true},
+		{"custom", testInputs, true},
+		{"empty", Inputs{}, true},
+		{"missing name", Inputs{{Input: "hdmi1"}}, false},
+		{"neither input nor app", Inputs{{Name: "hdmi1"}}, false},
+		{"both input and app", Inputs{{Name: "hdmi1", Input: "hdmi1", AppID: "youtube.leanback.v4"}}, false},
+		{"unknown input", Inputs{{Name: "hdmi9", Input: "hdmi9"}}, false},
+		{"invalid app", Inputs{{Name: "app", AppID: "youtube;reboot"}}, false},
+		{"duplicate name", Inputs{{Name: "hdmi1", Input: "hdmi1"}, {Name: "HDMI1", Input: "hdmi2"}}, false},
+		{"duplicate alias", Inputs{
+			{Name: "hdmi1", Aliases: []string{"console"}, Input: "hdmi1"},
+			{Name: "hdmi2", Aliases: []string{"Console"}, Input: "hdmi2"},
+		}, false},
+		{"alias of another name", Inputs{
+			{Name: "hdmi1", Input: "hdmi1"},
+			{Name: "hdmi2", Aliases: []string{"hdmi1"}, Input: "hdmi2"},
+		}, false},
+	}
+	for _, tc := range cases {
+		err := tc.inputs.Validate()
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestLoadInputs(t *testing.T) {
+	cases := []struct {
+		name     string
+		data     string
+		expected int
+	}{
+		{"inputs", `[
+			{"name": "hdmi1", "aliases": ["Apple TV"], "input": "hdmi1"},
+			{"name": "youtube", "app_id": "youtube.leanback.v4", "expected_app": "youtube"}
+		]`, 2},
+		{"empty", `[]`, 0},
+		{"malformed", `[{"name": "hdmi1"`, -1},
+		{"not an array", `{"name": "hdmi1", "input": "hdmi1"}`, -1},
+		{"invalid", `[{"name": "hdmi1", "input": "hdmi1", "app_id": "youtube.leanback.v4"}]`, -1},
+	}
+	for _, tc := range cases {
+		inputs, err := LoadInputs(strings.NewReader(tc.data))
+		if tc.expected < 0 {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if len(inputs) != tc.expected {
+			t.Errorf("%s: expected %d inputs, received %d", tc.name, tc.expected, len(inputs))
+		}
+	}
+
+	inputs, err := LoadInputs(strings.NewReader(`[{"name": "youtube", "app_id": "youtube.leanback.v4", "expected_app": "youtube"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in := inputs[0]; in.Command() != "APP_LAUNCH youtube.leanback.v4" || in.Expected() != "youtube" {
+		t.Errorf("unexpected input: %+v", in)
+	}
+}
@@ -0,0 +1,458 @@
+// Package iptest provides a fake LG TV that speaks the IP control protocol,
+// for testing clients of the ip package without real hardware.
+package iptest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+)
+
+// State is the simulated state of the TV.
+type State struct {
+	Power           bool
+	IPControl       bool
+	Volume          int
+	Muted           bool
+	App             string
+	MACAddressWired string
+	MACAddressWifi  string
+	PictureMode     string
+	EnergySaving    string
+	AspectRatio     string
+	Backlight       int
+	ScreenMuted     bool
+
+	// Channel is the tuner channel, which is changed while watching live TV
+	// by entering a number followed by OK, or with channel up and down.
+	Channel string
+
+	entry string
+}
+
+func defaultState() State {
+	return State{
+		Power:           true,
+		IPControl:       true,
+		Volume:          10,
+		App:             "com.webos.app.hdmi1",
+		MACAddressWired: "a0:b1:c2:d3:e4:01",
+		MACAddressWifi:  "a0:b1:c2:d3:e4:02",
+		PictureMode:     string(ip.PictureModeStandard),
+		EnergySaving:    string(ip.EnergySavingAuto),
+		AspectRatio:     string(ip.AspectRatio16x9),
+		Backlight:       80,
+		Channel:         "1",
+	}
+}
+
+// Faults are injected into the behavior of the server.
+type Faults struct {
+	// Delay is added before every response is written.
+	Delay time.Duration
+
+	// DropResponses causes commands to be received but never answered.
+	DropResponses bool
+
+	// NG is a list of command verbs that are always answered with NG.
+	NG []string
+
+	// Fragment causes responses to be written in several small writes.
+	Fragment bool
+
+	// WrongKey causes responses to be encrypted with a different key than
+	// the one the server was created with, as happens when a client is
+	// configured with the wrong key. For a plaintext server, responses are
+	// encrypted as if the device expects encryption.
+	WrongKey bool
+}
+
+type Server struct {
+	// Addr is the address the server is listening on.
+	Addr string
+
+	l        net.Listener
+	codec    ip.Codec
+	wrongEnc *ip.Encoder
+	logger   *slog.Logger
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	state    State
+	faults   Faults
+	conns    map[net.Conn]struct{}
+	commands []string
+}
+
+// NewServer starts a fake TV listening on a random local port, using key to
+// encrypt and decrypt messages.
+func NewServer(key string) (*Server, error) {
+	enc, err := ip.NewEncoder(key)
+	if err != nil {
+		return nil, err
+	}
+	return newServer(enc, key+"-wrong")
+}
+
+// NewPlaintextServer starts a fake TV listening on a random local port, using
+// the unencrypted protocol.
+func NewPlaintextServer() (*Server, error) {
+	return newServer(ip.PlaintextCodec{}, "wrong")
+}
+
+func newServer(codec ip.Codec, wrongKey string) (*Server, error) {
+	wrongEnc, err := ip.NewEncoder(wrongKey)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     l.Addr().String(),
+		l:        l,
+		codec:    codec,
+		wrongEnc: wrongEnc,
+		logger:   slog.Default().With(slog.String("component", "iptest")),
+		state:    defaultState(),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and closes all active connections.
+func (s *Server) Close() error {
+	err := s.l.Close()
+	s.DropConnections()
+	s.wg.Wait()
+	return err
+}
+
+// State returns the current simulated state.
+func (s *Server) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state
+}
+
+// SetState modifies the simulated state. Setting Power to false closes all
+// active connections and refuses new ones, like a TV in standby.
+func (s *Server) SetState(fn func(*State)) {
+	s.mu.Lock()
+	fn(&s.state)
+	power := s.state.Power
+	s.mu.Unlock()
+
+	if !power {
+		s.DropConnections()
+	}
+}
+
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = f
+}
+
+// DropConnections closes all active connections.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		_ = conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+// Commands returns every command received so far, in order.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.commands)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("accept failed", slog.Any("error", err))
+			}
+			return
+		}
+		s.mu.Lock()
+		if !s.state.Power {
+			s.mu.Unlock()
+			_ = conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	fr := s.codec.NewDecoder(conn)
+	for {
+		plaintext, err := fr.ReadFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Anything that cannot be decoded is rejected before closing the
+			// connection, so that a client using the wrong key or protocol
+			// can tell.
+			s.logger.Debug("cannot read command", slog.Any("error", err))
+			_ = write(conn, s.codec.Encode([]byte("NG")), false)
+			return
+		}
+		command := strings.TrimSpace(string(plaintext))
+		resp, faults := s.handle(command)
+		if faults.DropResponses {
+			continue
+		}
+		if faults.Delay > 0 {
+			time.Sleep(faults.Delay)
+		}
+		var enc ip.Codec = s.codec
+		if faults.WrongKey {
+			enc = s.wrongEnc
+		}
+		if err := write(conn, enc.Encode([]byte(resp)), faults.Fragment); err != nil {
+			return
+		}
+		if command == "POWER off" && resp == "OK" {
+			s.SetState(func(state *State) { state.Power, state.ScreenMuted = false, false })
+			return
+		}
+	}
+}
+
+// write writes the frame to the connection, optionally in fragments to
+// simulate partial reads.
+func write(conn net.Conn, frame []byte, fragment bool) error {
+	if !fragment {
+		_, err := conn.Write(frame)
+		return err
+	}
+	for chunk := range slices.Chunk(frame, 5) {
+		if _, err := conn.Write(chunk); err != nil {
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+// handle applies the command to the simulated state and returns the
+// response, along with the faults active at the time.
+func (s *Server) handle(command string) (string, Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = append(s.commands, command)
+	verb, arg, _ := strings.Cut(command, " ")
+	if slices.Contains(s.faults.NG, verb) {
+		return "NG", s.faults
+	}
+	h, ok := handlers[verb]
+	if !ok {
+		return "NG", s.faults
+	}
+	return h(&s.state, arg), s.faults
+}
+
+const liveTVApp = "com.webos.app.livetv"
+
+var handlers = map[string]func(s *State, arg string) string{
+	"POWER": func(s *State, arg string) string {
+		if arg != "off" {
+			return "NG"
+		}
+		return "OK"
+	},
+	"GET_IPCONTROL_STATE": func(s *State, _ string) string {
+		if s.IPControl {
+			return "ON"
+		}
+		return "OFF"
+	},
+	"GET_MACADDRESS": func(s *State, arg string) string {
+		switch arg {
+		case "wired":
+			return s.MACAddressWired
+		case "wifi":
+			return s.MACAddressWifi
+		default:
+			return "NG"
+		}
+	},
+	"CURRENT_VOL": func(s *State, _ string) string {
+		return fmt.Sprintf("VOL:%d", s.Volume)
+	},
+	"MUTE_STATE": func(s *State, _ string) string {
+		if s.Muted {
+			return "MUTE:on"
+		}
+		return "MUTE:off"
+	},
+	"CURRENT_APP": func(s *State, _ string) string {
+		return "APP:" + s.App
+	},
+	"VOLUME_CONTROL": func(s *State, arg string) string {
+		v, err := strconv.Atoi(arg)
+		if err != nil || v < ip.MinVolume || v > ip.MaxVolume {
+			return "NG"
+		}
+		s.Volume = v
+		return "OK"
+	},
+	"VOLUME_MUTE": func(s *State, arg string) string {
+		switch arg {
+		case "on":
+			s.Muted = true
+		case "off":
+			s.Muted = false
+		default:
+			return "NG"
+		}
+		return "OK"
+	},
+	"INPUT_SELECT": func(s *State, arg string) string {
+		if arg == "" {
+			return "NG"
+		}
+		switch arg {
+		case "atv", "dtv":
+			s.App = liveTVApp
+		case "av1":
+			s.App = "com.webos.app.externalinput.av1"
+		case "component1":
+			s.App = "com.webos.app.externalinput.component"
+		default:
+			s.App = "com.webos.app." + arg
+		}
+		return "OK"
+	},
+	"APP_LAUNCH": func(s *State, arg string) string {
+		if arg == "" {
+			return "NG"
+		}
+		s.App = arg
+		return "OK"
+	},
+	"PICTURE_MODE": func(s *State, arg string) string {
+		if !ip.PictureMode(arg).Valid() {
+			return "NG"
+		}
+		s.PictureMode = arg
+		return "OK"
+	},
+	"ENERGY_SAVING": func(s *State, arg string) string {
+		if !ip.EnergySaving(arg).Valid() {
+			return "NG"
+		}
+		s.EnergySaving = arg
+		return "OK"
+	},
+	"ASPECT_RATIO": func(s *State, arg string) string {
+		if !ip.AspectRatio(arg).Valid() {
+			return "NG"
+		}
+		s.AspectRatio = arg
+		return "OK"
+	},
+	"PICTURE_BACKLIGHT": func(s *State, arg string) string {
+		v, err := strconv.Atoi(arg)
+		if err != nil || v < ip.MinBacklight || v > ip.MaxBacklight {
+			return "NG"
+		}
+		s.Backlight = v
+		return "OK"
+	},
+	"SCREEN_MUTE": func(s *State, arg string) string {
+		switch ip.ScreenMute(arg) {
+		case ip.ScreenMuteOn, ip.ScreenMuteVideo:
+			s.ScreenMuted = true
+		case ip.ScreenMuteOff:
+			s.ScreenMuted = false
+		default:
+			return "NG"
+		}
+		return "OK"
+	},
+	"KEY_ACTION": func(s *State, arg string) string {
+		key := ip.Key(arg)
+		if n, ok := strings.CutPrefix(arg, "number"); ok && key.Valid() {
+			if s.App == liveTVApp {
+				s.entry += n
+			}
+			return "OK"
+		}
+		switch key {
+		case ip.KeyDash:
+			if s.App == liveTVApp {
+				s.entry += "-"
+			}
+		case ip.KeyOK:
+			if s.entry != "" {
+				s.Channel, s.entry = s.entry, ""
+			}
+		case ip.KeyChannelUp, ip.KeyChannelDown:
+			if s.App != liveTVApp {
+				break
+			}
+			if n, err := strconv.Atoi(s.Channel); err == nil {
+				if key == ip.KeyChannelUp {
+					s.Channel = strconv.Itoa(n + 1)
+				} else {
+					s.Channel = strconv.Itoa(max(n-1, 1))
+				}
+			}
+		case ip.KeyLiveTV:
+			s.App = liveTVApp
+		case ip.KeyVolumeUp:
+			s.Volume = min(s.Volume+1, ip.MaxVolume)
+		case ip.KeyVolumeDown:
+			s.Volume = max(s.Volume-1, ip.MinVolume)
+		case ip.KeyVolumeMute:
+			s.Muted = !s.Muted
+		default:
+			if !key.Valid() {
+				return "NG"
+			}
+		}
+		return "OK"
+	},
+}
@@ -0,0 +1,342 @@
+package iptest_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+	"go.chrisrx.dev/webos/ip/iptest"
+)
+
+const testKey = "ABCD1234"
+
+type conn struct {
+	net.Conn
+	codec ip.Codec
+	fr    ip.Decoder
+	reads []int
+}
+
+func newServer(t *testing.T) *iptest.Server {
+	t.Helper()
+
+	s, err := iptest.NewServer(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// dial connects to the server, recording the size of every read from the
+// connection.
+func dial(t *testing.T, s *iptest.Server, codec ip.Codec) *conn {
+	t.Helper()
+
+	nc, err := net.Dial("tcp", s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = nc.Close() })
+
+	c := &conn{Conn: nc, codec: codec}
+	c.fr = codec.NewDecoder(readerFunc(func(p []byte) (int, error) {
+		n, err := nc.Read(p)
+		c.reads = append(c.reads, n)
+		return n, err
+	}))
+	return c
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (fn readerFunc) Read(p []byte) (int, error) {
+	return fn(p)
+}
+
+// send writes the command and returns the response, or the error from
+// reading it.
+func (c *conn) send(t *testing.T, command string) (string, error) {
+	t.Helper()
+
+	if _, err := c.Write(c.codec.Encode([]byte(command))); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.fr.ReadFrame()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(resp)), nil
+}
+
+func (c *conn) mustSend(t *testing.T, command string) string {
+	t.Helper()
+
+	resp, err := c.send(t, command)
+	if err != nil {
+		t.Fatalf("%s: %v", command, err)
+	}
+	return resp
+}
+
+func encoder(t *testing.T) *ip.Encoder {
+	t.Helper()
+
+	enc, err := ip.NewEncoder(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+func TestServer(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s, encoder(t))
+
+	cases := []struct {
+		command  string
+		expected string
+	}{
+		{"GET_IPCONTROL_STATE", "ON"},
+		{"GET_MACADDRESS wired", "a0:b1:c2:d3:e4:01"},
+		{"GET_MACADDRESS wifi", "a0:b1:c2:d3:e4:02"},
+		{"CURRENT_VOL", "VOL:10"},
+		{"VOLUME_CONTROL 25", "OK"},
+		{"CURRENT_VOL", "VOL:25"},
+		{"VOLUME_CONTROL 101", "NG"},
+		{"MUTE_STATE", "MUTE:off"},
+		{"VOLUME_MUTE on", "OK"},
+		{"MUTE_STATE", "MUTE:on"},
+		{"CURRENT_APP", "APP:com.webos.app.hdmi1"},
+		{"INPUT_SELECT hdmi2", "OK"},
+		{"CURRENT_APP", "APP:com.webos.app.hdmi2"},
+		{"PICTURE_MODE cinema", "OK"},
+		{"PICTURE_BACKLIGHT 50", "OK"},
+		{"SCREEN_MUTE screenmuteon", "OK"},
+		{"KEY_ACTION volumeup", "OK"},
+		{"KEY_ACTION notakey", "NG"},
+		{"FACTORY_RESET", "NG"},
+	}
+	var commands []string
+	for _, tc := range cases {
+		if resp := c.mustSend(t, tc.command); resp != tc.expected {
+			t.Errorf("%s: expected %q, received %q", tc.command, tc.expected, resp)
+		}
+		commands = append(commands, tc.command)
+	}
+
+	state := s.State()
+	if state.Volume != 26 || !state.Muted || state.App != "com.webos.app.hdmi2" ||
+		state.PictureMode != "cinema" || state.Backlight != 50 || !state.ScreenMuted {
+		t.Errorf("unexpected state: %+v", state)
+	}
+	if received := s.Commands(); !slices.Equal(received, commands) {
+		t.Errorf("expected commands %q, received %q", commands, received)
+	}
+}
+
+func TestServerSetState(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s, encoder(t))
+
+	s.SetState(func(state *iptest.State) { state.Volume = 42 })
+	if resp := c.mustSend(t, "CURRENT_VOL"); resp != "VOL:42" {
+		t.Errorf("expected VOL:42, received %q", resp)
+	}
+
+	s.SetState(func(state *iptest.State) { state.IPControl = false })
+	if resp := c.mustSend(t, "GET_IPCONTROL_STATE"); resp != "OFF" {
+		t.Errorf("expected OFF, received %q", resp)
+	}
+}
+
+func TestServerDelay(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s, encoder(t))
+
+	const delay = 200 * time.Millisecond
+	s.SetFaults(iptest.Faults{Delay: delay})
+
+	start := time.Now()
+	if resp := c.mustSend(t, "CURRENT_VOL"); resp != "VOL:10" {
+		t.Errorf("expected VOL:10, received %q", resp)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("expected response after at least %v, received after %v", delay, elapsed)
+	}
+}
+
+func TestServerDropResponses(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s, encoder(t))
+
+	s.SetFaults(iptest.Faults{DropResponses: true})
+	if _, err := c.send(t, "VOLUME_CONTROL 30"); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected no response, received %v", err)
+	}
+	// The command is still applied.
+	if volume := s.State().Volume; volume != 30 {
+		t.Errorf("expected volume 30, received %d", volume)
+	}
+
+	s.SetFaults(iptest.Faults{})
+	if resp := c.mustSend(t, "CURRENT_VOL"); resp != "VOL:30" {
+		t.Errorf("expected VOL:30, received %q", resp)
+	}
+}
+
+func TestServerNG(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s, encoder(t))
+
+	s.SetFaults(iptest.Faults{NG: []string{"VOLUME_CONTROL", "CURRENT_APP"}})
+	for _, command := range []string{"VOLUME_CONTROL 30", "CURRENT_APP"} {
+		if resp := c.mustSend(t, command); resp != "NG" {
+			t.Errorf("%s: expected NG, received %q", command, resp)
+		}
+	}
+	if volume := s.State().Volume; volume != 10 {
+		t.Errorf("expected volume to be unchanged, received %d", volume)
+	}
+	if resp := c.mustSend(t, "CURRENT_VOL"); resp != "VOL:10" {
+		t.Errorf("expected VOL:10, received %q", resp)
+	}
+}
+
+func TestServerFragment(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s, encoder(t))
+
+	s.SetFaults(iptest.Faults{Fragment: true})
+	if resp := c.mustSend(t, "CURRENT_APP"); resp != "APP:com.webos.app.hdmi1" {
+		t.Errorf("expected APP:com.webos.app.hdmi1, received %q", resp)
+	}
+	if len(c.reads) < 2 {
+		t.Errorf("expected the response to be read in fragments, received reads of %v bytes", c.reads)
+	}
+}
+
+func TestServerWrongKey(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s, encoder(t))
+
+	s.SetFaults(iptest.Faults{WrongKey: true})
+	_, err := c.send(t, "CURRENT_VOL")
+	if !errors.Is(err, ip.ErrWrongKey) && !errors.Is(err, ip.ErrBadPadding) {
+		t.Fatalf("expected ErrWrongKey or ErrBadPadding, received %v", err)
+	}
+}
+
+func TestServerPowerOff(t *testing.T) {
+	s := newServer(t)
+	c := dial(t, s, encoder(t))
+	other := dial(t, s, encoder(t))
+	other.mustSend(t, "CURRENT_VOL")
+
+	s.SetState(func(state *iptest.State) { state.ScreenMuted = true })
+	if resp := c.mustSend(t, "POWER off"); resp != "OK" {
+		t.Fatalf("expected OK, received %q", resp)
+	}
+	// The TV turns off after acknowledging the command.
+	deadline := time.Now().Add(time.Second)
+	for s.State().Power && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state := s.State(); state.Power || state.ScreenMuted {
+		t.Errorf("expected TV to be off, received %+v", state)
+	}
+
+	// Every connection is dropped, and new connections are refused.
+	for _, c := range []*conn{c, other, dial(t, s, encoder(t))} {
+		if err := c.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.fr.ReadFrame(); !errors.Is(err, io.EOF) {
+			t.Errorf("expected connection to be closed, received %v", err)
+		}
+	}
+
+	s.SetState(func(state *iptest.State) { state.Power = true })
+	if resp := dial(t, s, encoder(t)).mustSend(t, "CURRENT_VOL"); resp != "VOL:10" {
+		t.Errorf("expected VOL:10, received %q", resp)
+	}
+}
+
+func TestPlaintextServer(t *testing.T) {
+	s, err := iptest.NewPlaintextServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c := dial(t, s, ip.PlaintextCodec{})
+	for _, tc := range []struct {
+		command  string
+		expected string
+	}{
+		{"GET_IPCONTROL_STATE", "ON"},
+		{"CURRENT_VOL", "VOL:10"},
+		{"VOLUME_CONTROL 25", "OK"},
+		{"CURRENT_VOL", "VOL:25"},
+		{"INPUT_SELECT hdmi2", "OK"},
+		{"CURRENT_APP", "APP:com.webos.app.hdmi2"},
+		{"FACTORY_RESET", "NG"},
+	} {
+		if resp := c.mustSend(t, tc.command); resp != tc.expected {
+			t.Errorf("%s: expected %q, received %q", tc.command, tc.expected, resp)
+		}
+	}
+
+	s.SetFaults(iptest.Faults{WrongKey: true})
+	if _, err := c.send(t, "CURRENT_VOL"); !errors.Is(err, ip.ErrWrongKey) {
+		t.Errorf("expected ErrWrongKey, received %v", err)
+	}
+}
+
+func TestServerUndecodable(t *testing.T) {
+	plaintext, err := iptest.NewPlaintextServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plaintext.Close()
+
+	for _, tc := range []struct {
+		name  string
+		s     *iptest.Server
+		codec ip.Codec
+		data  []byte
+	}{
+		// Two blocks of plain text, which are decrypted into garbage.
+		{"encrypted server", newServer(t), encoder(t), []byte("CURRENT_VOL\rCURRENT_VOL\rMUTE_ST\r")},
+		{"plaintext server", plaintext, ip.PlaintextCodec{}, []byte("\x8f\x02\xe1CURRENT_VOL\r")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := dial(t, tc.s, tc.codec)
+			if _, err := c.Write(tc.data); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.fr.ReadFrame()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(resp) != "NG\r" && string(resp) != "NG" {
+				t.Errorf("expected NG, received %q", resp)
+			}
+			if _, err := c.fr.ReadFrame(); !errors.Is(err, io.EOF) {
+				t.Errorf("expected connection to be closed, received %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Key is a remote control key name accepted by the KEY_ACTION command.
+type Key string
+
+// Navigation
+const (
+	KeyArrowUp    Key = "arrowup"
+	KeyArrowDown  Key = "arrowdown"
+	KeyArrowLeft  Key = "arrowleft"
+	KeyArrowRight Key = "arrowright"
+	KeyOK         Key = "ok"
+	KeyBack       Key = "returnback"
+	KeyExit       Key = "exit"
+	KeyHome       Key = "myapp"
+	KeySettings   Key = "settingmenu"
+	KeyQuickMenu  Key = "quickmenu"
+)
+
+// Numbers
+const (
+	KeyNumber0 Key = "number0"
+	KeyNumber1 Key = "number1"
+	KeyNumber2 Key = "number2"
+	KeyNumber3 Key = "number3"
+	KeyNumber4 Key = "number4"
+	KeyNumber5 Key = "number5"
+	KeyNumber6 Key = "number6"
+	KeyNumber7 Key = "number7"
+	KeyNumber8 Key = "number8"
+	KeyNumber9 Key = "number9"
+	KeyDash    Key = "dash"
+)
+
+// Colour
+const (
+	KeyRed    Key = "redbutton"
+	KeyGreen  Key = "greenbutton"
+	KeyYellow Key = "yellowbutton"
+	KeyBlue   Key = "bluebutton"
+)
+
+// Media
+const (
+	KeyPlay        Key = "play"
+	KeyPause       Key = "pause"
+	KeyStop        Key = "stop"
+	KeyFastForward Key = "fastforward"
+	KeyRewind      Key = "rewind"
+	KeyRecord      Key = "record"
+)
+
+// Volume
+const (
+	KeyVolumeUp   Key = "volumeup"
+	KeyVolumeDown Key = "volumedown"
+	KeyVolumeMute Key = "volumemute"
+)
+
+// Channels
+const (
+	KeyChannelUp       Key = "channelup"
+	KeyChannelDown     Key = "channeldown"
+	KeyChannelList     Key = "channellist"
+	KeyPreviousChannel Key = "previouschannel"
+	KeyProgramGuide    Key = "programguide"
+	KeyLiveTV          Key = "livetv"
+)
+
+// Settings
+const (
+	KeyInfo             Key = "programminfo"
+	KeyInput            Key = "deviceinput"
+	KeyAspectRatio      Key = "aspectratio"
+	KeyAudioMode        Key = "audiomode"
+	KeyVideoMode        Key = "videomode"
+	KeyEnergySaving     Key = "screenbright"
+	KeySleepTimer       Key = "sleepreserve"
+	KeySubtitle         Key = "captionsubtitle"
+	KeyAudioDescription Key = "audiodescription"
+	KeyUserGuide        Key = "userguide"
+	KeyScreenRemote     Key = "screenremote"
+)
+
+var keys = []Key{
+	KeyArrowUp, KeyArrowDown, KeyArrowLeft, KeyArrowRight, KeyOK, KeyBack,
+	KeyExit, KeyHome, KeySettings, KeyQuickMenu,
+
+	KeyNumber0, KeyNumber1, KeyNumber2, KeyNumber3, KeyNumber4, KeyNumber5,
+	KeyNumber6, KeyNumber7, KeyNumber8, KeyNumber9, KeyDash,
+
+	KeyRed, KeyGreen, KeyYellow, KeyBlue,
+
+	KeyPlay, KeyPause, KeyStop, KeyFastForward, KeyRewind, KeyRecord,
+
+	KeyVolumeUp, KeyVolumeDown, KeyVolumeMute,
+
+	KeyChannelUp, KeyChannelDown, KeyChannelList, KeyPreviousChannel,
+	KeyProgramGuide, KeyLiveTV,
+
+	KeyInfo, KeyInput, KeyAspectRatio, KeyAudioMode, KeyVideoMode,
+	KeyEnergySaving, KeySleepTimer, KeySubtitle, KeyAudioDescription,
+	KeyUserGuide, KeyScreenRemote,
+}
+
+// keyAliases maps friendlier names to the key names used by the protocol.
+var keyAliases = map[string]Key{
+	"up":     KeyArrowUp,
+	"down":   KeyArrowDown,
+	"left":   KeyArrowLeft,
+	"right":  KeyArrowRight,
+	"enter":  KeyOK,
+	"select": KeyOK,
+	"back":   KeyBack,
+	"home":   KeyHome,
+	"menu":   KeySettings,
+	"red":    KeyRed,
+	"green":  KeyGreen,
+	"yellow": KeyYellow,
+	"blue":   KeyBlue,
+	"mute":   KeyVolumeMute,
+	"guide":  KeyProgramGuide,
+	"info":   KeyInfo,
+	"input":  KeyInput,
+}
+
+// Keys returns all supported keys.
+func Keys() []Key {
+	return slices.Clone(keys)
+}
+
+// Valid reports whether k is a supported key.
+func (k Key) Valid() bool {
+	return slices.Contains(keys, k)
+}
+
+func (k Key) String() string {
+	return string(k)
+}
+
+var ErrUnknownKey = errors.New("unknown key")
+
+// ParseKey returns the Key for the provided name. Names are matched case
+// insensitively and may also be one of the common aliases (e.g. "up" or
+// "back").
+func ParseKey(name string) (Key, error) {
+	s := strings.ToLower(strings.TrimSpace(name))
+	if k := Key(s); k.Valid() {
+		return k, nil
+	}
+	if k, ok := keyAliases[s]; ok {
+		return k, nil
+	}
+	var suggestions []string
+	if s != "" {
+		for _, k := range keys {
+			if strings.Contains(string(k), s) {
+				suggestions = append(suggestions, string(k))
+			}
+		}
+	}
+	if len(suggestions) > 0 {
+		return "", fmt.Errorf("%w: %q, did you mean one of: %s", ErrUnknownKey, name, strings.Join(suggestions, ", "))
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownKey, name)
+}
+
+// PressKey sends a single key press to the device.
+func (c *Client) PressKey(ctx context.Context, key Key) error {
+	if !key.Valid() {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, key)
+	}
+	_, err := c.Do(ctx, fmt.Sprintf("KEY_ACTION %s", key))
+	return err
+}
@@ -0,0 +1,141 @@
+package ip
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// lateResponseGrace is how long a command that timed out waiting for its
+// response stays pending. A response that arrives late is matched to it
+// during this time instead of being mistaken for the response to a later
+// command. If the device never responds, a later command with the same kind
+// of response may be reported as not responding instead.
+const lateResponseGrace = 2 * time.Second
+
+type pendingCommand struct {
+	command string
+	result  chan result
+
+	// timedOut is when the command stopped waiting for its response, or zero
+	// while it is still waiting.
+	timedOut time.Time
+}
+
+// pendingCommands tracks commands that have been written to the connection
+// but not yet received a response.
+type pendingCommands struct {
+	mu       sync.Mutex
+	commands []*pendingCommand
+}
+
+func (p *pendingCommands) add(command string) *pendingCommand {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pc := &pendingCommand{
+		command: command,
+		result:  make(chan result, 1),
+	}
+	p.commands = append(p.commands, pc)
+	return pc
+}
+
+func (p *pendingCommands) remove(pc *pendingCommand) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.commands = slices.DeleteFunc(p.commands, func(v *pendingCommand) bool {
+		return v == pc
+	})
+}
+
+// timeout marks the command as no longer waiting for its response, keeping
+// it pending for lateResponseGrace. It returns false if the command was
+// resolved in the meantime, in which case its result is ready.
+func (p *pendingCommands) timeout(pc *pendingCommand, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !slices.Contains(p.commands, pc) {
+		return false
+	}
+	pc.timedOut = now
+	return true
+}
+
+// resolve delivers the response to the oldest pending command it could be
+// the response for. It returns the command and whether it had already timed
+// out, or false if the response does not match any pending command. Commands
+// that timed out more than lateResponseGrace ago are dropped first.
+func (p *pendingCommands) resolve(resp string, now time.Time) (command string, late bool, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.commands = slices.DeleteFunc(p.commands, func(pc *pendingCommand) bool {
+		return !pc.timedOut.IsZero() && now.Sub(pc.timedOut) > lateResponseGrace
+	})
+	for i, pc := range p.commands {
+		if !expects(pc.command, resp) {
+			continue
+		}
+		p.commands = slices.Delete(p.commands, i, i+1)
+		if !pc.timedOut.IsZero() {
+			return pc.command, true, true
+		}
+		pc.result <- result{resp: Response{Command: pc.command, Value: resp}}
+		return pc.command, false, true
+	}
+	return "", false, false
+}
+
+// fail delivers err to every pending command.
+func (p *pendingCommands) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pc := range p.commands {
+		if pc.timedOut.IsZero() {
+			pc.result <- result{err: err}
+		}
+	}
+	p.commands = nil
+}
+
+// unsolicitedQueries are the queries whose responses are distinct enough to
+// be applied to the device state even when no command is waiting for them,
+// such as a response that arrives after lateResponseGrace.
+var unsolicitedQueries = []string{"CURRENT_VOL", "MUTE_STATE", "CURRENT_APP"}
+
+// dispatch routes a response read from the connection to the pending command
+// it belongs to.
+func (c *Client) dispatch(resp string) {
+	if command, late, ok := c.pending.resolve(resp, time.Now()); ok {
+		// Nothing is waiting for a late response anymore, but it still
+		// reflects the device state.
+		if late {
+			c.logger.Debug("applying late response",
+				slog.String("command", command),
+				slog.String("response", resp),
+			)
+			if err := c.update(command, resp); err != nil {
+				c.logger.Warn("late response", slog.String("command", command), slog.Any("error", err))
+			}
+		}
+		return
+	}
+	for _, query := range unsolicitedQueries {
+		if expects(query, resp) {
+			c.logger.Debug("applying unsolicited response",
+				slog.String("command", query),
+				slog.String("response", resp),
+			)
+			if err := c.update(query, resp); err != nil {
+				c.logger.Error("invalid unsolicited response", slog.Any("error", err))
+			}
+			return
+		}
+	}
+	c.logger.Debug("discarding unsolicited response", slog.String("response", resp))
+}
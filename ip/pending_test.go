@@ -0,0 +1,78 @@
+package ip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingCommands(t *testing.T) {
+	var p pendingCommands
+	now := time.Now()
+
+	vol := p.add("VOLUME_CONTROL 30")
+	query := p.add("CURRENT_VOL")
+	key := p.add("KEY_ACTION ok")
+
+	// The first response matching either query is delivered to the oldest.
+	if command, late, ok := p.resolve("VOL:30", now); !ok || late || command != "CURRENT_VOL" {
+		t.Fatalf("expected CURRENT_VOL, received %q late=%v ok=%v", command, late, ok)
+	}
+	if r := <-query.result; r.resp.Value != "VOL:30" {
+		t.Errorf("expected VOL:30, received %q", r.resp.Value)
+	}
+
+	// A late response is absorbed by the command that timed out instead of
+	// being delivered to the next command.
+	if !p.timeout(vol, now) {
+		t.Fatal("expected command to be pending")
+	}
+	if command, late, ok := p.resolve("NG", now.Add(lateResponseGrace)); !ok || !late || command != "VOLUME_CONTROL 30" {
+		t.Fatalf("expected late VOLUME_CONTROL 30, received %q late=%v ok=%v", command, late, ok)
+	}
+	select {
+	case r := <-key.result:
+		t.Fatalf("expected KEY_ACTION ok to be pending, received %+v", r)
+	default:
+	}
+	if command, late, ok := p.resolve("OK", now); !ok || late || command != "KEY_ACTION ok" {
+		t.Fatalf("expected KEY_ACTION ok, received %q late=%v ok=%v", command, late, ok)
+	}
+	if _, _, ok := p.resolve("OK", now); ok {
+		t.Error("expected no pending command")
+	}
+}
+
+func TestPendingCommandsGrace(t *testing.T) {
+	var p pendingCommands
+	now := time.Now()
+
+	vol := p.add("VOLUME_CONTROL 30")
+	if !p.timeout(vol, now) {
+		t.Fatal("expected command to be pending")
+	}
+	key := p.add("KEY_ACTION ok")
+
+	// Once the grace period has passed, the timed out command no longer
+	// absorbs responses.
+	if command, late, ok := p.resolve("OK", now.Add(lateResponseGrace+time.Millisecond)); !ok || late || command != "KEY_ACTION ok" {
+		t.Fatalf("expected KEY_ACTION ok, received %q late=%v ok=%v", command, late, ok)
+	}
+	if r := <-key.result; r.resp.Value != "OK" {
+		t.Errorf("expected OK, received %q", r.resp.Value)
+	}
+}
+
+func TestPendingCommandsTimeoutResolved(t *testing.T) {
+	var p pendingCommands
+
+	pc := p.add("CURRENT_VOL")
+	p.resolve("VOL:10", time.Now())
+	// The response arrived just before the timeout, so it must still be
+	// received.
+	if p.timeout(pc, time.Now()) {
+		t.Fatal("expected command to be resolved")
+	}
+	if r := <-pc.result; r.resp.Value != "VOL:10" {
+		t.Errorf("expected VOL:10, received %q", r.resp.Value)
+	}
+}
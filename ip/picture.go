@@ -0,0 +1,190 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PictureMode is a picture preset accepted by the PICTURE_MODE command.
+type PictureMode string
+
+const (
+	PictureModeVivid       PictureMode = "vivid"
+	PictureModeStandard    PictureMode = "normal"
+	PictureModeEco         PictureMode = "eco"
+	PictureModeCinema      PictureMode = "cinema"
+	PictureModeSports      PictureMode = "sports"
+	PictureModeGame        PictureMode = "game"
+	PictureModePhoto       PictureMode = "photo"
+	PictureModeFilmMaker   PictureMode = "filmMaker"
+	PictureModeTechnicolor PictureMode = "technicolor"
+	PictureModeExpert1     PictureMode = "expert1"
+	PictureModeExpert2     PictureMode = "expert2"
+	PictureModeHDREffect   PictureMode = "hdrEffect"
+)
+
+var pictureModes = []PictureMode{
+	PictureModeVivid, PictureModeStandard, PictureModeEco, PictureModeCinema,
+	PictureModeSports, PictureModeGame, PictureModePhoto, PictureModeFilmMaker,
+	PictureModeTechnicolor, PictureModeExpert1, PictureModeExpert2,
+	PictureModeHDREffect,
+}
+
+// PictureModes returns all supported picture modes.
+func PictureModes() []PictureMode {
+	return slices.Clone(pictureModes)
+}
+
+func (m PictureMode) Valid() bool {
+	return slices.Contains(pictureModes, m)
+}
+
+// ParsePictureMode returns the PictureMode for the name, matched case
+// insensitively. "standard" is accepted for PictureModeStandard.
+func ParsePictureMode(name string) (PictureMode, error) {
+	if strings.EqualFold(name, "standard") {
+		return PictureModeStandard, nil
+	}
+	return parseValue("picture mode", pictureModes, name)
+}
+
+// EnergySaving is a level accepted by the ENERGY_SAVING command.
+type EnergySaving string
+
+const (
+	EnergySavingAuto      EnergySaving = "auto"
+	EnergySavingOff       EnergySaving = "off"
+	EnergySavingMinimum   EnergySaving = "minimum"
+	EnergySavingMedium    EnergySaving = "medium"
+	EnergySavingMaximum   EnergySaving = "maximum"
+	EnergySavingScreenOff EnergySaving = "screenoff"
+)
+
+var energySavingLevels = []EnergySaving{
+	EnergySavingAuto, EnergySavingOff, EnergySavingMinimum, EnergySavingMedium,
+	EnergySavingMaximum, EnergySavingScreenOff,
+}
+
+// EnergySavingLevels returns all supported energy saving levels.
+func EnergySavingLevels() []EnergySaving {
+	return slices.Clone(energySavingLevels)
+}
+
+func (e EnergySaving) Valid() bool {
+	return slices.Contains(energySavingLevels, e)
+}
+
+// ParseEnergySaving returns the EnergySaving level for the name, matched case
+// insensitively.
+func ParseEnergySaving(name string) (EnergySaving, error) {
+	return parseValue("energy saving level", energySavingLevels, name)
+}
+
+// AspectRatio is a value accepted by the ASPECT_RATIO command.
+type AspectRatio string
+
+const (
+	AspectRatio16x9     AspectRatio = "16x9"
+	AspectRatio4x3      AspectRatio = "4x3"
+	AspectRatio21x9     AspectRatio = "21x9"
+	AspectRatioOriginal AspectRatio = "original"
+	AspectRatioJustScan AspectRatio = "justscan"
+	AspectRatioZoom     AspectRatio = "zoom"
+)
+
+var aspectRatios = []AspectRatio{
+	AspectRatio16x9, AspectRatio4x3, AspectRatio21x9, AspectRatioOriginal,
+	AspectRatioJustScan, AspectRatioZoom,
+}
+
+// AspectRatios returns all supported aspect ratios.
+func AspectRatios() []AspectRatio {
+	return slices.Clone(aspectRatios)
+}
+
+func (a AspectRatio) Valid() bool {
+	return slices.Contains(aspectRatios, a)
+}
+
+// ParseAspectRatio returns the AspectRatio for the name, matched case
+// insensitively. "16:9" style names are also accepted.
+func ParseAspectRatio(name string) (AspectRatio, error) {
+	return parseValue("aspect ratio", aspectRatios, strings.ReplaceAll(name, ":", "x"))
+}
+
+const (
+	MinBacklight = 0
+	MaxBacklight = 100
+)
+
+func parseValue[T ~string](kind string, values []T, name string) (T, error) {
+	name = strings.TrimSpace(name)
+	for _, v := range values {
+		if strings.EqualFold(string(v), name) {
+			return v, nil
+		}
+	}
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = string(v)
+	}
+	return "", fmt.Errorf("%w: unknown %s %q, expected one of: %s", ErrInvalidCommand, kind, name, strings.Join(names, ", "))
+}
+
+// SetPictureMode changes the picture preset.
+func (c *Client) SetPictureMode(ctx context.Context, mode PictureMode) error {
+	if !mode.Valid() {
+		return fmt.Errorf("%w: unknown picture mode %q", ErrInvalidCommand, mode)
+	}
+	_, err := c.Do(ctx, fmt.Sprintf("PICTURE_MODE %s", mode))
+	return err
+}
+
+// PictureMode returns the picture preset last set by the client.
+func (c *Client) PictureMode() PictureMode {
+	return c.GetState().PictureMode
+}
+
+// SetEnergySaving changes the energy saving level, which also affects the
+// brightness of the screen.
+func (c *Client) SetEnergySaving(ctx context.Context, level EnergySaving) error {
+	if !level.Valid() {
+		return fmt.Errorf("%w: unknown energy saving level %q", ErrInvalidCommand, level)
+	}
+	_, err := c.Do(ctx, fmt.Sprintf("ENERGY_SAVING %s", level))
+	return err
+}
+
+// EnergySaving returns the energy saving level last set by the client.
+func (c *Client) EnergySaving() EnergySaving {
+	return c.GetState().EnergySaving
+}
+
+// SetAspectRatio changes the aspect ratio of the current input.
+func (c *Client) SetAspectRatio(ctx context.Context, ratio AspectRatio) error {
+	if !ratio.Valid() {
+		return fmt.Errorf("%w: unknown aspect ratio %q", ErrInvalidCommand, ratio)
+	}
+	_, err := c.Do(ctx, fmt.Sprintf("ASPECT_RATIO %s", ratio))
+	return err
+}
+
+// AspectRatio returns the aspect ratio last set by the client.
+func (c *Client) AspectRatio() AspectRatio {
+	return c.GetState().AspectRatio
+}
+
+// SetBacklight sets the backlight level, clamped to the range supported by
+// the device. Energy saving levels other than off may override it.
+func (c *Client) SetBacklight(ctx context.Context, level int) error {
+	level = min(max(level, MinBacklight), MaxBacklight)
+	_, err := c.Do(ctx, fmt.Sprintf("PICTURE_BACKLIGHT %d", level))
+	return err
+}
+
+// Backlight returns the backlight level last set by the client.
+func (c *Client) Backlight() int64 {
+	return c.GetState().Backlight
+}
@@ -0,0 +1,94 @@
+package ip
+
+import (
+	"time"
+)
+
+// PollQuery is a query sent periodically to keep the device state up to date.
+type PollQuery struct {
+	Command  string
+	Interval time.Duration
+
+	// Boost causes the query to be sent at the boost interval for a short
+	// time after any interactive command, since that is when the state it
+	// reports is most likely to change.
+	Boost bool
+}
+
+// DefaultPollQueries are the queries used when none are provided with
+// WithPollQueries.
+var DefaultPollQueries = []PollQuery{
+	{Command: "GET_MACADDRESS wired", Interval: 5 * time.Minute},
+	{Command: "GET_MACADDRESS wifi", Interval: 5 * time.Minute},
+	{Command: "MUTE_STATE", Interval: 5 * time.Second, Boost: true},
+	{Command: "CURRENT_VOL", Interval: 5 * time.Second, Boost: true},
+	{Command: "CURRENT_APP", Interval: 2 * time.Second, Boost: true},
+	{Command: "GET_IPCONTROL_STATE", Interval: 30 * time.Second},
+}
+
+const (
+	defaultBoostInterval = 500 * time.Millisecond
+	defaultBoostDuration = 5 * time.Second
+
+	// pollResolution is how often the schedule is checked for queries that
+	// are due.
+	pollResolution = 100 * time.Millisecond
+)
+
+// WithPollQueries replaces the queries that are periodically sent to update
+// device state.
+func WithPollQueries(queries ...PollQuery) Option {
+	return func(client *Client) {
+		client.pollQueries = queries
+	}
+}
+
+// WithPollBoost sets the interval used for boosted queries and how long after
+// an interactive command it applies. A zero duration disables boosting.
+func WithPollBoost(interval, duration time.Duration) Option {
+	return func(client *Client) {
+		client.boostInterval = interval
+		client.boostDuration = duration
+	}
+}
+
+// PausePolling stops all background queries until ResumePolling is called.
+// Polling is also paused automatically whenever the device is not powered on.
+func (c *Client) PausePolling() {
+	c.pollPaused.Store(true)
+}
+
+func (c *Client) ResumePolling() {
+	c.pollPaused.Store(false)
+}
+
+// pollLoop sends each query whenever its interval has elapsed.
+func (c *Client) pollLoop() {
+	ticker := time.NewTicker(pollResolution)
+	defer ticker.Stop()
+
+	last := make([]time.Time, len(c.pollQueries))
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+		if c.pollPaused.Load() || !c.connected.Load() || c.GetState().Power != PowerStateOn {
+			continue
+		}
+		now := time.Now()
+		boosted := now.Sub(time.Unix(0, c.lastInteractive.Load())) < c.boostDuration
+		for i, q := range c.pollQueries {
+			interval := q.Interval
+			if q.Boost && boosted {
+				interval = min(interval, c.boostInterval)
+			}
+			if now.Sub(last[i]) < interval {
+				continue
+			}
+			last[i] = now
+			c.poll(q.Command)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.chrisrx.dev/group"
+)
+
+type PowerState string
+
+const (
+	// PowerStateUnknown is used when the device is unreachable and there is
+	// no other indication of what state it is in.
+	PowerStateUnknown PowerState = "unknown"
+
+	// PowerStateOff is used when the device could not be woken up and is
+	// most likely completely powered off.
+	PowerStateOff PowerState = "off"
+
+	// PowerStateStandby is used after the device has been powered off by the
+	// client and is expected to respond to WOL.
+	PowerStateStandby PowerState = "standby"
+
+	// PowerStateTurningOn is used after a WOL packet has been sent, until the
+	// device connects or turningOnTimeout elapses.
+	PowerStateTurningOn PowerState = "turning_on"
+
+	PowerStateOn PowerState = "on"
+)
+
+// turningOnTimeout is how long the device has to become reachable after WOL
+// packets are sent before it is considered to be off.
+const turningOnTimeout = 30 * time.Second
+
+// transitionPower sets the power state to the state returned by fn.
+func (c *Client) transitionPower(fn func(current PowerState) PowerState) {
+	c.updateState(func(s *State) {
+		s.Power = fn(s.Power)
+		s.UpdatedAt.Power = time.Now()
+	})
+}
+
+// PowerOff powers off the device, which is confirmed by the device closing the
+// connection.
+func (c *Client) PowerOff() error {
+	// The device may close the connection before responding, which is
+	// reported as no response.
+	if err := c.apply(c.ctx, OperationPower, "POWER off", "", func(s State) bool {
+		return !s.Connected
+	}); err != nil {
+		return err
+	}
+	c.transitionPower(func(PowerState) PowerState { return PowerStateStandby })
+	c.updateState(func(s *State) {
+		s.ScreenMuted = false
+		s.UpdatedAt.ScreenMuted = time.Now()
+	})
+	return nil
+}
+
+func (c *Client) PowerOn() error {
+	state := c.GetState()
+	if state.MACAddressWifi == "" && state.MACAddressWired == "" && c.macAddr == "" {
+		return fmt.Errorf("mac address must be provided to send WOL packet")
+	}
+	g := group.New(c.ctx)
+	for _, addr := range []string{state.MACAddressWifi, state.MACAddressWired, c.macAddr} {
+		if addr == "" {
+			continue
+		}
+		g.Go(func(ctx context.Context) error {
+			return c.wol.Send(addr)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	c.transitionPower(func(current PowerState) PowerState {
+		if current == PowerStateOn {
+			return current
+		}
+		c.turningOnDeadline = time.Now().Add(turningOnTimeout)
+		if c.turningOnTimer != nil {
+			c.turningOnTimer.Stop()
+		}
+		c.turningOnTimer = time.AfterFunc(turningOnTimeout, c.turningOnExpired)
+		return PowerStateTurningOn
+	})
+	// The device will not be reachable before the WOL packets are sent, so
+	// any backoff from prior connection attempts is skipped.
+	c.reconnect()
+	return nil
+}
+
+// turningOnExpired considers the device to be off if it is still turning on
+// once turningOnTimeout has elapsed.
+func (c *Client) turningOnExpired() {
+	if c.ctx.Err() != nil {
+		return
+	}
+	c.transitionPower(func(current PowerState) PowerState {
+		// The deadline is checked since PowerOn may have been called again
+		// after this timer fired but before the state could be updated.
+		if current == PowerStateTurningOn && time.Now().After(c.turningOnDeadline) {
+			return PowerStateOff
+		}
+		return current
+	})
+}
+
+var ErrPowerOnTimeout = errors.New("timed out waiting for device to power on")
+
+const (
+	powerOnInitialInterval = 1 * time.Second
+	powerOnMaxInterval     = 10 * time.Second
+)
+
+// PowerOnAndWait powers on the device and waits until it can be controlled,
+// meaning a connection has been established and the device reports that IP
+// control is enabled. WOL packets are resent with backoff until then, or
+// until ctx is done, in which case ErrPowerOnTimeout is returned.
+func (c *Client) PowerOnAndWait(ctx context.Context) error {
+	interval := powerOnInitialInterval
+	for {
+		if err := c.PowerOn(); err != nil {
+			return err
+		}
+		if c.waitControllable(ctx, interval) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %w", ErrPowerOnTimeout, err)
+		}
+		interval = min(interval*2, powerOnMaxInterval)
+	}
+}
+
+// waitControllable waits up to timeout for the device to be connected and
+// report that IP control is enabled.
+func (c *Client) waitControllable(ctx context.Context, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		if err := c.WaitFor(ctx, func(s State) bool { return s.Connected }); err != nil {
+			return false
+		}
+		resp, err := c.Do(ctx, "GET_IPCONTROL_STATE")
+		if err == nil && parseBool(resp.Value) {
+			return true
+		}
+		select {
+		case <-time.After(c.retryPolicy(OperationPower).Interval):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// TogglePower powers off the device if it is on, otherwise it attempts to
+// power it on.
+func (c *Client) TogglePower() error {
+	if c.GetState().Power == PowerStateOn {
+		return c.PowerOff()
+	}
+	return c.PowerOn()
+}
@@ -0,0 +1,43 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestPowerOnTimerStoppedOnClose(t *testing.T) {
+	wol, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wol.Close()
+
+	dialer := DialerFunc(func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("unreachable")
+	})
+	c, err := New(context.Background(), "192.0.2.1:9761", "ABCD1234",
+		WithDialer(dialer),
+		WithMACAddress("a0:b1:c2:d3:e4:01"),
+		WithWOL(WOLConfig{Targets: []string{wol.LocalAddr().String()}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PowerOn(); err != nil {
+		t.Fatal(err)
+	}
+	if power := c.GetState().Power; power != PowerStateTurningOn {
+		t.Fatalf("expected power state %q, received %q", PowerStateTurningOn, power)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.turningOnTimer.Stop() {
+		t.Error("expected timer to be stopped by Close")
+	}
+}
@@ -0,0 +1,243 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Priority int
+
+const (
+	// PriorityInteractive is used for commands sent on behalf of a user and
+	// are always sent before any queued polls.
+	PriorityInteractive Priority = iota
+
+	// PriorityPoll is used for the background queries that update device
+	// state.
+	PriorityPoll
+)
+
+// OverflowPolicy determines what happens to interactive commands when the
+// queue is full. Polls are always dropped when the queue is full, since they
+// will be retried on the next polling interval anyway.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room in the queue until the context of the
+	// command is done.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowReject rejects the new command with ErrQueueFull.
+	OverflowReject
+
+	// OverflowDropOldest discards the oldest queued command, which receives
+	// ErrDropped, to make room for the new command.
+	OverflowDropOldest
+)
+
+var (
+	ErrQueueFull = errors.New("command queue is full")
+	ErrDropped   = errors.New("command dropped from queue")
+)
+
+const (
+	defaultQueueSize  = 10
+	defaultCommandTTL = 5 * time.Second
+
+	// expireInterval is how often queued commands are checked for expiry
+	// while waiting for the connection.
+	expireInterval = 100 * time.Millisecond
+)
+
+// QueueStats are counters for commands that did not make it through the
+// queue as submitted.
+type QueueStats struct {
+	Queued    int
+	Dropped   uint64
+	Coalesced uint64
+	Expired   uint64
+}
+
+// queue is a bounded command queue with separate lanes for each priority.
+type queue struct {
+	size   int
+	policy OverflowPolicy
+
+	mu     sync.Mutex
+	lanes  [2][]*request
+	space  chan struct{}
+	ready  chan struct{}
+	closed bool
+
+	dropped   atomic.Uint64
+	coalesced atomic.Uint64
+	expired   atomic.Uint64
+}
+
+func newQueue(size int, policy OverflowPolicy) *queue {
+	return &queue{
+		size:   size,
+		policy: policy,
+		space:  make(chan struct{}),
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push adds the request to the queue, applying the overflow policy if the
+// lane for its priority is full.
+func (q *queue) push(ctx context.Context, req *request) error {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return fmt.Errorf("%w: %q", ErrClosed, req.command)
+		}
+		lane := q.lanes[req.priority]
+		if req.priority == PriorityPoll {
+			// A poll that is already queued will update the same state, so
+			// the new request is merged into the existing one.
+			if i := slices.IndexFunc(lane, func(r *request) bool {
+				return r.command == req.command
+			}); i >= 0 {
+				lane[i].waiters = append(lane[i].waiters, req.waiters...)
+				q.mu.Unlock()
+				q.coalesced.Add(1)
+				return nil
+			}
+		}
+		if len(lane) < q.size {
+			q.lanes[req.priority] = append(lane, req)
+			q.mu.Unlock()
+			q.signal()
+			return nil
+		}
+		policy := q.policy
+		if req.priority == PriorityPoll {
+			policy = OverflowReject
+		}
+		switch policy {
+		case OverflowReject:
+			q.mu.Unlock()
+			q.dropped.Add(1)
+			return fmt.Errorf("%w: %q", ErrQueueFull, req.command)
+		case OverflowDropOldest:
+			oldest := lane[0]
+			q.lanes[req.priority] = append(lane[1:], req)
+			q.mu.Unlock()
+			q.dropped.Add(1)
+			oldest.done(Response{}, &CommandError{Command: oldest.command, Err: ErrDropped})
+			q.signal()
+			return nil
+		default:
+			space := q.space
+			q.mu.Unlock()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				q.dropped.Add(1)
+				return fmt.Errorf("%w: %q: %w", ErrQueueFull, req.command, ctx.Err())
+			}
+		}
+	}
+}
+
+// pop waits for the next request, always preferring interactive commands
+// over polls.
+func (q *queue) pop(ctx context.Context) (*request, error) {
+	for {
+		q.mu.Lock()
+		for i, lane := range q.lanes {
+			if len(lane) == 0 {
+				continue
+			}
+			req := lane[0]
+			q.lanes[i] = lane[1:]
+			close(q.space)
+			q.space = make(chan struct{})
+			q.mu.Unlock()
+			return req, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// expire removes every queued request that has passed its deadline, along
+// with any that have been canceled.
+func (q *queue) expire(now time.Time) {
+	var expired []*request
+	removed := false
+	q.mu.Lock()
+	for i, lane := range q.lanes {
+		q.lanes[i] = slices.DeleteFunc(lane, func(r *request) bool {
+			switch {
+			case r.canceled.Load():
+			case r.expired(now):
+				expired = append(expired, r)
+			default:
+				return false
+			}
+			removed = true
+			return true
+		})
+	}
+	if removed {
+		close(q.space)
+		q.space = make(chan struct{})
+	}
+	q.mu.Unlock()
+
+	for _, r := range expired {
+		q.expired.Add(1)
+		r.done(Response{}, &CommandError{Command: r.command, Err: ErrExpired})
+	}
+}
+
+// close rejects every queued request with ErrClosed, along with any requests
+// pushed afterwards.
+func (q *queue) close() {
+	q.mu.Lock()
+	var rejected []*request
+	for i, lane := range q.lanes {
+		rejected = append(rejected, lane...)
+		q.lanes[i] = nil
+	}
+	if !q.closed {
+		q.closed = true
+		close(q.space)
+	}
+	q.mu.Unlock()
+
+	for _, r := range rejected {
+		r.done(Response{}, &CommandError{Command: r.command, Err: ErrClosed})
+	}
+}
+
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return QueueStats{
+		Queued:    len(q.lanes[PriorityInteractive]) + len(q.lanes[PriorityPoll]),
+		Dropped:   q.dropped.Load(),
+		Coalesced: q.coalesced.Load(),
+		Expired:   q.expired.Load(),
+	}
+}
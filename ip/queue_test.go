@@ -0,0 +1,184 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// popAll pops every queued request, returning their commands in order.
+func popAll(t *testing.T, q *queue) []string {
+	t.Helper()
+
+	var commands []string
+	for q.stats().Queued > 0 {
+		req, err := q.pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		commands = append(commands, req.command)
+	}
+	return commands
+}
+
+func mustPush(t *testing.T, q *queue, req *request) {
+	t.Helper()
+
+	if err := q.push(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	q := newQueue(defaultQueueSize, OverflowReject)
+	mustPush(t, q, newRequest("CURRENT_VOL", PriorityPoll, time.Time{}, false))
+	mustPush(t, q, newRequest("MUTE_STATE", PriorityPoll, time.Time{}, false))
+	mustPush(t, q, newRequest("VOLUME_CONTROL 20", PriorityInteractive, time.Time{}, false))
+	mustPush(t, q, newRequest("KEY_ACTION ok", PriorityInteractive, time.Time{}, false))
+
+	expected := []string{"VOLUME_CONTROL 20", "KEY_ACTION ok", "CURRENT_VOL", "MUTE_STATE"}
+	if commands := popAll(t, q); !slices.Equal(commands, expected) {
+		t.Errorf("expected %q, received %q", expected, commands)
+	}
+}
+
+func TestQueueCoalescePolls(t *testing.T) {
+	q := newQueue(defaultQueueSize, OverflowReject)
+	first := newRequest("CURRENT_VOL", PriorityPoll, time.Time{}, true)
+	second := newRequest("CURRENT_VOL", PriorityPoll, time.Time{}, true)
+	mustPush(t, q, first)
+	mustPush(t, q, second)
+	// Interactive commands are never coalesced.
+	mustPush(t, q, newRequest("KEY_ACTION ok", PriorityInteractive, time.Time{}, false))
+	mustPush(t, q, newRequest("KEY_ACTION ok", PriorityInteractive, time.Time{}, false))
+
+	if stats := q.stats(); stats.Queued != 3 || stats.Coalesced != 1 {
+		t.Fatalf("expected 3 queued and 1 coalesced, received %+v", stats)
+	}
+	popAll(t, q)
+
+	// Both callers receive the result of the single poll.
+	first.done(Response{Command: "CURRENT_VOL", Value: "VOL:10"}, nil)
+	for _, req := range []*request{first, second} {
+		if r := <-req.waiters[0]; r.err != nil || r.resp.Value != "VOL:10" {
+			t.Errorf("expected VOL:10, received %+v", r)
+		}
+	}
+}
+
+func TestQueueOverflowReject(t *testing.T) {
+	q := newQueue(1, OverflowReject)
+	mustPush(t, q, newRequest("KEY_ACTION ok", PriorityInteractive, time.Time{}, false))
+
+	if err := q.push(context.Background(), newRequest("KEY_ACTION exit", PriorityInteractive, time.Time{}, false)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, received %v", err)
+	}
+	if stats := q.stats(); stats.Queued != 1 || stats.Dropped != 1 {
+		t.Errorf("expected 1 queued and 1 dropped, received %+v", stats)
+	}
+}
+
+func TestQueueOverflowDropOldest(t *testing.T) {
+	q := newQueue(2, OverflowDropOldest)
+	oldest := newRequest("KEY_ACTION up", PriorityInteractive, time.Time{}, true)
+	mustPush(t, q, oldest)
+	mustPush(t, q, newRequest("KEY_ACTION down", PriorityInteractive, time.Time{}, false))
+	mustPush(t, q, newRequest("KEY_ACTION ok", PriorityInteractive, time.Time{}, false))
+
+	if r := <-oldest.waiters[0]; !errors.Is(r.err, ErrDropped) {
+		t.Errorf("expected ErrDropped, received %v", r.err)
+	}
+	if stats := q.stats(); stats.Queued != 2 || stats.Dropped != 1 {
+		t.Errorf("expected 2 queued and 1 dropped, received %+v", stats)
+	}
+	if commands := popAll(t, q); !slices.Equal(commands, []string{"KEY_ACTION down", "KEY_ACTION ok"}) {
+		t.Errorf("expected the oldest command to be dropped, received %q", commands)
+	}
+
+	// Polls are rejected rather than displacing queued polls.
+	mustPush(t, q, newRequest("CURRENT_VOL", PriorityPoll, time.Time{}, false))
+	mustPush(t, q, newRequest("MUTE_STATE", PriorityPoll, time.Time{}, false))
+	if err := q.push(context.Background(), newRequest("CURRENT_APP", PriorityPoll, time.Time{}, false)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, received %v", err)
+	}
+}
+
+func TestQueueOverflowBlock(t *testing.T) {
+	q := newQueue(1, OverflowBlock)
+	mustPush(t, q, newRequest("KEY_ACTION up", PriorityInteractive, time.Time{}, false))
+
+	// The push waits until the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := q.push(ctx, newRequest("KEY_ACTION down", PriorityInteractive, time.Time{}, false))
+	if !errors.Is(err, ErrQueueFull) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrQueueFull and context.DeadlineExceeded, received %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected push to block until the context is done, returned after %v", elapsed)
+	}
+	if stats := q.stats(); stats.Dropped != 1 {
+		t.Errorf("expected 1 dropped, received %+v", stats)
+	}
+
+	// The push succeeds once there is room.
+	pushed := make(chan error, 1)
+	go func() {
+		pushed <- q.push(context.Background(), newRequest("KEY_ACTION ok", PriorityInteractive, time.Time{}, false))
+	}()
+	select {
+	case err := <-pushed:
+		t.Fatalf("expected push to block, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if req, err := q.pop(context.Background()); err != nil || req.command != "KEY_ACTION up" {
+		t.Fatalf("expected KEY_ACTION up, received %v", err)
+	}
+	if err := <-pushed; err != nil {
+		t.Fatal(err)
+	}
+	if commands := popAll(t, q); !slices.Equal(commands, []string{"KEY_ACTION ok"}) {
+		t.Errorf("expected KEY_ACTION ok, received %q", commands)
+	}
+}
+
+func TestQueueExpire(t *testing.T) {
+	q := newQueue(defaultQueueSize, OverflowReject)
+	now := time.Now()
+	expired := newRequest("KEY_ACTION up", PriorityInteractive, now.Add(-time.Second), true)
+	canceled := newRequest("KEY_ACTION down", PriorityInteractive, now.Add(time.Second), true)
+	canceled.cancel()
+	mustPush(t, q, expired)
+	mustPush(t, q, canceled)
+	mustPush(t, q, newRequest("KEY_ACTION ok", PriorityInteractive, now.Add(time.Second), false))
+	mustPush(t, q, newRequest("CURRENT_VOL", PriorityPoll, time.Time{}, false))
+
+	q.expire(now)
+	if r := <-expired.waiters[0]; !errors.Is(r.err, ErrExpired) {
+		t.Errorf("expected ErrExpired, received %v", r.err)
+	}
+	// Canceled requests are removed, but are not counted as expired.
+	if stats := q.stats(); stats.Queued != 2 || stats.Expired != 1 {
+		t.Errorf("expected 2 queued and 1 expired, received %+v", stats)
+	}
+	if commands := popAll(t, q); !slices.Equal(commands, []string{"KEY_ACTION ok", "CURRENT_VOL"}) {
+		t.Errorf("expected KEY_ACTION ok and CURRENT_VOL, received %q", commands)
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	q := newQueue(defaultQueueSize, OverflowReject)
+	req := newRequest("KEY_ACTION ok", PriorityInteractive, time.Time{}, true)
+	mustPush(t, q, req)
+
+	q.close()
+	if r := <-req.waiters[0]; !errors.Is(r.err, ErrClosed) {
+		t.Errorf("expected ErrClosed, received %v", r.err)
+	}
+	if err := q.push(context.Background(), newRequest("CURRENT_VOL", PriorityPoll, time.Time{}, false)); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, received %v", err)
+	}
+}
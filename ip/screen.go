@@ -0,0 +1,44 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+)
+
+// ScreenMute is a value accepted by the SCREEN_MUTE command.
+type ScreenMute string
+
+const (
+	// ScreenMuteOn turns off the screen while audio keeps playing.
+	ScreenMuteOn ScreenMute = "screenmuteon"
+
+	// ScreenMuteVideo hides the video of the current input, but on-screen
+	// menus are still shown.
+	ScreenMuteVideo ScreenMute = "videomuteon"
+
+	// ScreenMuteOff turns the screen back on.
+	ScreenMuteOff ScreenMute = "allmuteoff"
+)
+
+// ScreenOff blanks the screen while audio keeps playing.
+func (c *Client) ScreenOff(ctx context.Context) error {
+	return c.setScreenMute(ctx, ScreenMuteOn)
+}
+
+func (c *Client) ScreenOn(ctx context.Context) error {
+	return c.setScreenMute(ctx, ScreenMuteOff)
+}
+
+// ToggleScreen turns the screen on if it was turned off by the client,
+// otherwise it turns it off.
+func (c *Client) ToggleScreen(ctx context.Context) error {
+	if c.GetState().ScreenMuted {
+		return c.ScreenOn(ctx)
+	}
+	return c.ScreenOff(ctx)
+}
+
+func (c *Client) setScreenMute(ctx context.Context, mute ScreenMute) error {
+	_, err := c.Do(ctx, fmt.Sprintf("SCREEN_MUTE %s", mute))
+	return err
+}
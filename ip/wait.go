@@ -0,0 +1,137 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+// ErrNotConfirmed is returned when the device accepted a command but never
+// reported the resulting state change.
+var ErrNotConfirmed = errors.New("state change not confirmed")
+
+// Operation identifies a kind of state change that is applied and then
+// confirmed by waiting for the device to report the new state.
+type Operation string
+
+const (
+	OperationVolume Operation = "volume"
+	OperationMute   Operation = "mute"
+	OperationInput  Operation = "input"
+	OperationPower  Operation = "power"
+)
+
+// RetryPolicy controls how a command is applied and confirmed.
+type RetryPolicy struct {
+	// Attempts is the number of times the command is sent before giving up.
+	Attempts int
+
+	// Timeout is how long each attempt waits for the change to be confirmed.
+	Timeout time.Duration
+
+	// Interval is how often the device is queried while waiting.
+	Interval time.Duration
+}
+
+// DefaultRetryPolicies are the policies used for any operation that is not
+// configured with WithRetryPolicy.
+var DefaultRetryPolicies = map[Operation]RetryPolicy{
+	OperationVolume: {Attempts: 2, Timeout: 3 * time.Second, Interval: 250 * time.Millisecond},
+	OperationMute:   {Attempts: 2, Timeout: 3 * time.Second, Interval: 250 * time.Millisecond},
+	OperationInput:  {Attempts: 3, Timeout: 4 * time.Second, Interval: 500 * time.Millisecond},
+	OperationPower:  {Attempts: 2, Timeout: 5 * time.Second, Interval: 500 * time.Millisecond},
+}
+
+// WithRetryPolicy sets how the operation is applied and confirmed.
+func WithRetryPolicy(op Operation, p RetryPolicy) Option {
+	return func(client *Client) {
+		if client.retry == nil {
+			client.retry = maps.Clone(DefaultRetryPolicies)
+		}
+		client.retry[op] = p
+	}
+}
+
+// WaitFor blocks until predicate is satisfied by the device state, or ctx is
+// done. The predicate is evaluated immediately and then each time the state is
+// updated, so it should not block.
+func (c *Client) WaitFor(ctx context.Context, predicate func(State) bool) error {
+	for {
+		c.stateMu.RLock()
+		state, changed := c.state, c.changed
+		c.stateMu.RUnlock()
+
+		if predicate(state) {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.ctx.Done():
+			return ErrClosed
+		}
+	}
+}
+
+func (c *Client) retryPolicy(op Operation) RetryPolicy {
+	if p, ok := c.retry[op]; ok {
+		return p
+	}
+	return DefaultRetryPolicies[op]
+}
+
+// apply sends the command and waits for the device state to satisfy
+// predicate, sending it again according to the retry policy of the operation.
+// While waiting, the query is sent periodically to refresh the state.
+func (c *Client) apply(ctx context.Context, op Operation, command, query string, predicate func(State) bool) error {
+	p := c.retryPolicy(op)
+	var err error
+	for attempt := 1; attempt <= max(p.Attempts, 1); attempt++ {
+		// A missing response doesn't mean the command failed, and the
+		// confirmation will determine whether it needs to be sent again.
+		if _, err := c.Do(ctx, command); err != nil && !errors.Is(err, ErrNoResponse) {
+			return err
+		}
+		if err = c.confirm(ctx, p, query, predicate); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrClosed) {
+			break
+		}
+		c.logger.Warn("state change not confirmed",
+			slog.String("command", command),
+			slog.Int("attempt", attempt),
+		)
+	}
+	return &CommandError{Command: command, Err: fmt.Errorf("%w: %w", ErrNotConfirmed, err)}
+}
+
+// confirm waits up to the policy timeout for the state to satisfy predicate,
+// sending the query at each interval. The query is optional.
+func (c *Client) confirm(ctx context.Context, p RetryPolicy, query string, predicate func(State) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	for {
+		if query != "" {
+			// Errors are ignored since the query is only used to trigger a
+			// state update, and WaitFor will observe anything it changes.
+			_, _ = c.Do(ctx, query)
+		}
+		if err := c.waitFor(ctx, p.Interval, predicate); err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// waitFor is WaitFor limited to timeout.
+func (c *Client) waitFor(ctx context.Context, timeout time.Duration, predicate func(State) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return c.WaitFor(ctx, predicate)
+}
@@ -0,0 +1,126 @@
+package ip_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+	"go.chrisrx.dev/webos/ip/iptest"
+)
+
+func TestWaitFor(t *testing.T) {
+	tv, client := newTestClient(t, ip.WithPollQueries())
+	ctx := context.Background()
+
+	// A predicate that is already satisfied returns immediately, even with a
+	// context that is done.
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := client.WaitFor(done, func(s ip.State) bool { return s.Connected }); err != nil {
+		t.Errorf("expected satisfied predicate to return immediately, received %v", err)
+	}
+
+	// The predicate is evaluated again when the state changes.
+	tv.SetState(func(s *iptest.State) { s.Volume = 42 })
+	time.AfterFunc(50*time.Millisecond, func() { _, _ = client.Do(ctx, "CURRENT_VOL") })
+	waitFor(t, client, func(s ip.State) bool { return s.CurrentVolume == 42 })
+
+	// The context ends the wait when the predicate is never satisfied.
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := client.WaitFor(timeout, func(s ip.State) bool { return s.CurrentVolume == 99 }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, received %v", err)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WaitFor(ctx, func(s ip.State) bool { return s.CurrentVolume == 99 }); !errors.Is(err, ip.ErrClosed) {
+		t.Errorf("expected ErrClosed, received %v", err)
+	}
+}
+
+// count returns how many times the TV received the command.
+func count(tv *iptest.Server, command string) int {
+	n := 0
+	for _, c := range tv.Commands() {
+		if c == command {
+			n++
+		}
+	}
+	return n
+}
+
+func TestApply(t *testing.T) {
+	policy := ip.RetryPolicy{Attempts: 3, Timeout: 200 * time.Millisecond, Interval: 50 * time.Millisecond}
+	newClient := func(t *testing.T) (*iptest.Server, *ip.Client) {
+		return newTestClient(t,
+			ip.WithPollQueries(),
+			ip.WithReadTimeout(100*time.Millisecond),
+			ip.WithRetryPolicy(ip.OperationVolume, policy),
+		)
+	}
+	ctx := context.Background()
+
+	t.Run("confirmed", func(t *testing.T) {
+		tv, client := newClient(t)
+		if err := client.SetVolume(ctx, 30); err != nil {
+			t.Fatal(err)
+		}
+		if n := count(tv, "VOLUME_CONTROL 30"); n != 1 {
+			t.Errorf("expected the command to be sent once, sent %d times", n)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		tv, client := newClient(t)
+		tv.SetFaults(iptest.Faults{NG: []string{"VOLUME_CONTROL"}})
+		// A rejected command is not sent again.
+		if err := client.SetVolume(ctx, 30); !errors.Is(err, ip.ErrNG) {
+			t.Fatalf("expected ErrNG, received %v", err)
+		}
+		if n := count(tv, "VOLUME_CONTROL 30"); n != 1 {
+			t.Errorf("expected the command to be sent once, sent %d times", n)
+		}
+	})
+
+	t.Run("not confirmed", func(t *testing.T) {
+		tv, client := newClient(t)
+		// The command is applied, but the change is never reported.
+		tv.SetFaults(iptest.Faults{NG: []string{"CURRENT_VOL"}})
+		if err := client.SetVolume(ctx, 30); !errors.Is(err, ip.ErrNotConfirmed) {
+			t.Fatalf("expected ErrNotConfirmed, received %v", err)
+		}
+		if n := count(tv, "VOLUME_CONTROL 30"); n != policy.Attempts {
+			t.Errorf("expected the command to be sent %d times, sent %d times", policy.Attempts, n)
+		}
+	})
+
+	t.Run("confirmed on retry", func(t *testing.T) {
+		tv, client := newClient(t)
+		tv.SetFaults(iptest.Faults{DropResponses: true})
+		time.AfterFunc(300*time.Millisecond, func() { tv.SetFaults(iptest.Faults{}) })
+		if err := client.SetVolume(ctx, 30); err != nil {
+			t.Fatal(err)
+		}
+		if n := count(tv, "VOLUME_CONTROL 30"); n < 2 || n > policy.Attempts {
+			t.Errorf("expected the command to be retried, sent %d times", n)
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		tv, client := newClient(t)
+		tv.SetFaults(iptest.Faults{DropResponses: true})
+		ctx, cancel := context.WithTimeout(ctx, 150*time.Millisecond)
+		defer cancel()
+		// No further attempts are made once the context is done.
+		if err := client.SetVolume(ctx, 30); !errors.Is(err, ip.ErrNotConfirmed) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected ErrNotConfirmed and context.DeadlineExceeded, received %v", err)
+		}
+		if n := count(tv, "VOLUME_CONTROL 30"); n != 1 {
+			t.Errorf("expected the command to be sent once, sent %d times", n)
+		}
+	})
+}
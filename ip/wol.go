@@ -2,9 +2,11 @@ package ip
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -15,18 +17,128 @@ const (
 	wolMACAddressCount    = 16
 	wolSyncByte           = 0xff
 	wolSyncCount          = 6
+	wolDefaultPort        = 9
 )
 
-func SendWOLPacket(addr string, timeout time.Duration) error {
+// WOLConfig configures how Wake-on-LAN magic packets are sent. The zero value
+// broadcasts a single packet to 255.255.255.255:9.
+type WOLConfig struct {
+	// Targets are the broadcast or unicast addresses that magic packets are
+	// sent to, either as "host" or "host:port".
+	Targets []string
+
+	// Interface is the name of a network interface. When set, packets are
+	// sent from this interface and its subnet-directed broadcast address is
+	// added to the targets.
+	Interface string
+
+	// Port is used for any target that does not specify a port.
+	Port int
+
+	// Password is an optional SecureOn password, given either as 6 bytes in
+	// MAC address form or 4 bytes in IPv4 address form.
+	Password string
+
+	// Repeat is the number of times the packet is sent to each target.
+	Repeat int
+
+	Timeout time.Duration
+}
+
+// ParseMAC parses a 6 byte MAC address using colons, dashes or dots as
+// separators, or no separators at all.
+func ParseMAC(s string) (net.HardwareAddr, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == macAddressBlocksCount*2 {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mac address %q: %w", s, err)
+		}
+		return net.HardwareAddr(b), nil
+	}
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(mac) != macAddressBlocksCount {
+		return nil, fmt.Errorf("invalid mac address %q: expected %d bytes", s, macAddressBlocksCount)
+	}
+	return mac, nil
+}
+
+// ParseSecureOnPassword parses a SecureOn password in either MAC address
+// form (6 bytes) or IPv4 address form (4 bytes).
+func ParseSecureOnPassword(s string) ([]byte, error) {
+	if ip := net.ParseIP(s).To4(); ip != nil && !strings.Contains(s, ":") {
+		return []byte(ip), nil
+	}
+	b, err := ParseMAC(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SecureOn password: %w", err)
+	}
+	return []byte(b), nil
+}
+
+// NewMagicPacket returns the magic packet for the MAC address, with the
+// SecureOn password appended if provided.
+func NewMagicPacket(mac net.HardwareAddr, password []byte) []byte {
+	magic := bytes.Repeat([]byte{wolSyncByte}, wolSyncCount)
+	for range wolMACAddressCount {
+		magic = append(magic, mac...)
+	}
+	return append(magic, password...)
+}
+
+// Send sends the magic packet for the MAC address to every configured target.
+func (cfg WOLConfig) Send(addr string) error {
+	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 5 * time.Second
 	}
+	port := cfg.Port
+	if port == 0 {
+		port = wolDefaultPort
+	}
+	repeat := max(cfg.Repeat, 1)
 
-	raddr, err := net.ResolveUDPAddr("udp4", "255.255.255.255:9")
+	mac, err := ParseMAC(addr)
 	if err != nil {
 		return err
 	}
-	conn, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	var password []byte
+	if cfg.Password != "" {
+		password, err = ParseSecureOnPassword(cfg.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	laddr := &net.UDPAddr{IP: net.IPv4zero}
+	targets := slices.Clone(cfg.Targets)
+	if cfg.Interface != "" {
+		ipnet, err := interfaceIPv4(cfg.Interface)
+		if err != nil {
+			return err
+		}
+		laddr.IP = ipnet.IP
+		targets = append(targets, broadcastAddr(ipnet).String())
+	}
+	if len(targets) == 0 {
+		targets = []string{net.IPv4bcast.String()}
+	}
+	raddrs := make([]*net.UDPAddr, 0, len(targets))
+	for _, target := range targets {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			target = net.JoinHostPort(target, strconv.Itoa(port))
+		}
+		raddr, err := net.ResolveUDPAddr("udp4", target)
+		if err != nil {
+			return err
+		}
+		raddrs = append(raddrs, raddr)
+	}
+
+	conn, err := net.ListenUDP("udp4", laddr)
 	if err != nil {
 		return err
 	}
@@ -35,24 +147,50 @@ func SendWOLPacket(addr string, timeout time.Duration) error {
 	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
 		return err
 	}
-	magic := bytes.Repeat([]byte{wolSyncByte}, 6+6*16)
-	macAddrressBlocks := strings.Split(addr, ":")
-	for i := 0; i < wolMACAddressCount; i++ {
-		for j := 0; j < len(macAddrressBlocks); j++ {
-			index := wolSyncCount + i*macAddressBlocksCount + j
-			v, err := strconv.ParseUint(macAddrressBlocks[j], 16, 8)
-			if err != nil {
+	magic := NewMagicPacket(mac, password)
+	for _, raddr := range raddrs {
+		for range repeat {
+			if _, err := conn.WriteTo(magic, raddr); err != nil {
 				return err
 			}
-			magic[index] = byte(v)
 		}
-	}
-	slog.Default().Debug("magic packet sent",
-		slog.String("mac", addr),
-		slog.String("packet", fmt.Sprintf("%X", magic)),
-	)
-	if _, err := conn.WriteTo(magic, raddr); err != nil {
-		return err
+		slog.Default().Debug("magic packet sent",
+			slog.String("mac", mac.String()),
+			slog.String("target", raddr.String()),
+			slog.String("packet", fmt.Sprintf("%X", magic)),
+		)
 	}
 	return nil
 }
+
+// SendWOLPacket broadcasts a magic packet for the MAC address using the
+// default WOLConfig.
+func SendWOLPacket(addr string, timeout time.Duration) error {
+	return WOLConfig{Timeout: timeout}.Send(addr)
+}
+
+func interfaceIPv4(name string) (*net.IPNet, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return &net.IPNet{IP: ipnet.IP.To4(), Mask: ipnet.Mask[len(ipnet.Mask)-net.IPv4len:]}, nil
+		}
+	}
+	return nil, fmt.Errorf("interface %q has no IPv4 address", name)
+}
+
+// broadcastAddr returns the subnet-directed broadcast address of ipnet.
+func broadcastAddr(ipnet *net.IPNet) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	for i := range ip {
+		ip[i] = ipnet.IP[i] | ^ipnet.Mask[i]
+	}
+	return ip
+}
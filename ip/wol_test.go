@@ -0,0 +1,158 @@
+package ip
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// listenWOL returns a UDP listener on a random local port to receive magic
+// packets.
+func listenWOL(t *testing.T) *net.UDPConn {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// receive reads every packet that arrives within the timeout.
+func receive(t *testing.T, conn *net.UDPConn, timeout time.Duration) [][]byte {
+	t.Helper()
+
+	var packets [][]byte
+	buf := make([]byte, 1024)
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			t.Fatal(err)
+		}
+		n, err := conn.Read(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return packets
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, bytes.Clone(buf[:n]))
+	}
+}
+
+func TestWOLSend(t *testing.T) {
+	mac := []byte{0xa0, 0xb1, 0xc2, 0xd3, 0xe4, 0x01}
+	expected := func(password ...byte) []byte {
+		packet := bytes.Repeat([]byte{0xff}, 6)
+		packet = append(packet, bytes.Repeat(mac, 16)...)
+		return append(packet, password...)
+	}
+
+	cases := []struct {
+		name     string
+		addr     string
+		password string
+		repeat   int
+		expected []byte
+	}{
+		{"colons", "a0:b1:c2:d3:e4:01", "", 0, expected()},
+		{"dashes", "A0-B1-C2-D3-E4-01", "", 1, expected()},
+		{"no separators", "a0b1c2d3e401", "", 3, expected()},
+		{"password as mac", "a0:b1:c2:d3:e4:01", "01:02:03:04:05:06", 2, expected(1, 2, 3, 4, 5, 6)},
+		{"password as ipv4", "a0:b1:c2:d3:e4:01", "192.168.1.10", 1, expected(192, 168, 1, 10)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := listenWOL(t)
+			cfg := WOLConfig{
+				Targets:  []string{conn.LocalAddr().String()},
+				Password: tc.password,
+				Repeat:   tc.repeat,
+			}
+			if err := cfg.Send(tc.addr); err != nil {
+				t.Fatal(err)
+			}
+
+			packets := receive(t, conn, 200*time.Millisecond)
+			if len(packets) != max(tc.repeat, 1) {
+				t.Fatalf("expected %d packets, received %d", max(tc.repeat, 1), len(packets))
+			}
+			for _, packet := range packets {
+				if !bytes.Equal(packet, tc.expected) {
+					t.Errorf("expected packet %X, received %X", tc.expected, packet)
+				}
+			}
+		})
+	}
+}
+
+func TestWOLSendPort(t *testing.T) {
+	conn := listenWOL(t)
+	cfg := WOLConfig{
+		Targets: []string{"127.0.0.1"},
+		Port:    conn.LocalAddr().(*net.UDPAddr).Port,
+	}
+	if err := cfg.Send("a0:b1:c2:d3:e4:01"); err != nil {
+		t.Fatal(err)
+	}
+	if packets := receive(t, conn, 200*time.Millisecond); len(packets) != 1 {
+		t.Fatalf("expected 1 packet, received %d", len(packets))
+	}
+}
+
+func TestWOLSendInvalid(t *testing.T) {
+	for _, cfg := range []struct {
+		addr     string
+		password string
+	}{
+		{"a0:b1:c2:d3:e4", ""},
+		{"a0b1c2d3e4", ""},
+		{"not a mac", ""},
+		{"a0:b1:c2:d3:e4:01", "secret"},
+		{"a0:b1:c2:d3:e4:01", "01:02:03"},
+	} {
+		if err := (WOLConfig{Targets: []string{"127.0.0.1"}, Password: cfg.password}).Send(cfg.addr); err == nil {
+			t.Errorf("Send(%q) with password %q: expected error", cfg.addr, cfg.password)
+		}
+	}
+}
+
+// The interface broadcast address must not be appended to the caller's
+// targets, which may be shared between concurrent calls.
+func TestWOLSendDoesNotModifyTargets(t *testing.T) {
+	conn := listenWOL(t)
+	targets := make([]string, 1, 2)
+	targets[0] = conn.LocalAddr().String()
+
+	cfg := WOLConfig{Targets: targets, Interface: "lo"}
+	if err := cfg.Send("a0:b1:c2:d3:e4:01"); err != nil {
+		t.Skipf("cannot send from loopback interface: %v", err)
+	}
+	if spare := targets[:2][1]; spare != "" {
+		t.Errorf("expected targets to be unmodified, received %q appended", spare)
+	}
+}
+
+func TestBroadcastAddr(t *testing.T) {
+	for _, tc := range []struct {
+		cidr     string
+		expected string
+	}{
+		{"192.168.1.20/24", "192.168.1.255"},
+		{"10.0.17.5/20", "10.0.31.255"},
+		{"172.16.0.1/16", "172.16.255.255"},
+		{"192.168.1.20/32", "192.168.1.20"},
+	} {
+		ip, ipnet, err := net.ParseCIDR(tc.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ipnet.IP = ip.To4()
+		if addr := broadcastAddr(ipnet).String(); addr != tc.expected {
+			t.Errorf("%s: expected %s, received %s", tc.cidr, tc.expected, addr)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Package rs232 controls LG displays using the RS-232 serial protocol (e.g.
+// "ka 01 01"), either directly over a serial port or through a serial-to-TCP
+// bridge.
+package rs232
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+)
+
+// State has the same shape as the state reported by ip.Client. Only the power,
+// volume, mute and input (as CurrentApp) fields are reported over RS-232.
+type State = ip.State
+
+type Option func(*Client)
+
+func WithLogger(l *slog.Logger) Option {
+	return func(client *Client) {
+		client.logger = l
+	}
+}
+
+// WithSetID sets the ID of the device being controlled. The default is 1,
+// and 0 addresses every device on the bus.
+func WithSetID(id int) Option {
+	return func(client *Client) {
+		client.setID = id
+	}
+}
+
+// WithTimeout sets how long to wait for the device to acknowledge a command.
+func WithTimeout(d time.Duration) Option {
+	return func(client *Client) {
+		client.timeout = d
+	}
+}
+
+const (
+	defaultSetID   = 1
+	defaultTimeout = 2 * time.Second
+
+	// maxAckSize limits how much is read while waiting for the "x" that ends
+	// an acknowledgement.
+	maxAckSize = 64
+)
+
+type Client struct {
+	// mu is held for the duration of each command, since acknowledgements
+	// can only be matched to commands by their order.
+	mu   sync.Mutex
+	rw   io.ReadWriter
+	dial func(context.Context) (io.ReadWriter, error)
+
+	// acks receives the acknowledgements read from rw by readAcks, which
+	// stops once stop is closed.
+	acks <-chan ackResult
+	stop chan struct{}
+
+	stateMu sync.RWMutex
+	state   State
+
+	setID   int
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+// New returns a client that sends commands over rw, such as an open serial
+// port.
+func New(rw io.ReadWriter, opts ...Option) *Client {
+	c := newClient(opts...)
+	c.setConn(rw)
+	return c
+}
+
+// Dial returns a client for a serial-to-TCP bridge at addr. The connection is
+// established on the first command, and again after any connection error.
+func Dial(addr string, opts ...Option) *Client {
+	c := newClient(opts...)
+	c.dial = func(ctx context.Context) (io.ReadWriter, error) {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	return c
+}
+
+func newClient(opts ...Option) *Client {
+	c := &Client{
+		setID:   defaultSetID,
+		timeout: defaultTimeout,
+		logger:  slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.state.Power = ip.PowerStateUnknown
+	return c
+}
+
+func (c *Client) setConn(rw io.ReadWriter) {
+	acks := make(chan ackResult)
+	c.rw, c.acks, c.stop = rw, acks, make(chan struct{})
+	go readAcks(bufio.NewReader(rw), acks, c.stop)
+	c.updateState(func(s *State) {
+		s.Connected = true
+		s.UpdatedAt.Connected = time.Now()
+	})
+}
+
+// Close closes the underlying stream if it implements io.Closer.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closeConn()
+}
+
+func (c *Client) closeConn() error {
+	if c.rw == nil {
+		return nil
+	}
+	var err error
+	if closer, ok := c.rw.(io.Closer); ok {
+		err = closer.Close()
+	}
+	close(c.stop)
+	c.rw, c.acks, c.stop = nil, nil, nil
+	c.updateState(func(s *State) {
+		s.Connected = false
+		s.UpdatedAt.Connected = time.Now()
+	})
+	return err
+}
+
+// Send sends a command and returns its acknowledgement. A *CommandError
+// wrapping ErrNG is returned if the device rejects the command.
+func (c *Client) Send(ctx context.Context, cmd Command, data byte) (Ack, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ack, err := c.send(ctx, cmd, data)
+	if err != nil {
+		c.setError(err)
+		return Ack{}, &CommandError{Command: strings.TrimSpace(string(Encode(cmd, c.setID, data))), Ack: ack, Err: err}
+	}
+	return ack, nil
+}
+
+func (c *Client) send(ctx context.Context, cmd Command, data byte) (Ack, error) {
+	reused := c.rw != nil
+	ack, err := c.sendOnce(ctx, cmd, data)
+	// A bridge may have closed an idle connection since the last command.
+	// Every command sets or reads an absolute value, so it is safe to send
+	// it again on a new connection.
+	if err != nil && reused && c.rw == nil && c.dial != nil && ctx.Err() == nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		c.logger.Debug("retrying command on new connection", slog.Any("error", err))
+		return c.sendOnce(ctx, cmd, data)
+	}
+	return ack, err
+}
+
+func (c *Client) sendOnce(ctx context.Context, cmd Command, data byte) (Ack, error) {
+	if c.rw == nil {
+		if c.dial == nil {
+			return Ack{}, io.ErrClosedPipe
+		}
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+
+		rw, err := c.dial(ctx)
+		if err != nil {
+			return Ack{}, err
+		}
+		c.setConn(rw)
+	}
+	// An acknowledgement that arrived after its command gave up waiting
+	// would otherwise be mistaken for that of this command.
+	if err := c.drainAcks(); err != nil {
+		c.reset()
+		return Ack{}, err
+	}
+	if d, ok := c.rw.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		if err := d.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+			return Ack{}, err
+		}
+	}
+	if _, err := c.rw.Write(Encode(cmd, c.setID, data)); err != nil {
+		c.reset()
+		return Ack{}, err
+	}
+	ack, err := c.waitAck(ctx)
+	if err != nil {
+		c.reset()
+		return Ack{}, err
+	}
+	if ack.Command != cmd[1] || (c.setID != 0 && ack.SetID != c.setID) {
+		return ack, fmt.Errorf("%w: %q for %q", ErrInvalidAck, ack, cmd)
+	}
+	if !ack.OK {
+		return ack, ErrNG
+	}
+	return ack, nil
+}
+
+// reset drops the connection after an I/O error, since any acknowledgement
+// still to arrive would be mistaken for that of the next command. Streams
+// passed to New are kept, since they cannot be reopened.
+func (c *Client) reset() {
+	if c.dial == nil {
+		return
+	}
+	_ = c.closeConn()
+}
+
+type ackResult struct {
+	ack Ack
+	err error
+}
+
+// waitAck waits for the next acknowledgement until ctx is done or the
+// timeout passes. Reads cannot be interrupted on every stream, so they happen
+// in readAcks instead.
+func (c *Client) waitAck(ctx context.Context) (Ack, error) {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case r, ok := <-c.acks:
+		if !ok {
+			return Ack{}, io.ErrClosedPipe
+		}
+		return r.ack, r.err
+	case <-ctx.Done():
+		return Ack{}, ctx.Err()
+	case <-timer.C:
+		return Ack{}, fmt.Errorf("%w: no acknowledgement within %v", os.ErrDeadlineExceeded, c.timeout)
+	}
+}
+
+// drainAcks discards any acknowledgements that are ready. It returns an error
+// if reading has failed since the last command, such as when a bridge closes
+// an idle connection.
+func (c *Client) drainAcks() error {
+	for {
+		select {
+		case r, ok := <-c.acks:
+			if !ok {
+				return io.ErrClosedPipe
+			}
+			if r.err != nil && !errors.Is(r.err, ErrInvalidAck) {
+				return r.err
+			}
+			c.logger.Debug("discarding late acknowledgement", slog.Any("ack", r.ack), slog.Any("error", r.err))
+		default:
+			return nil
+		}
+	}
+}
+
+// readAcks reads acknowledgements from r until reading fails or stop is
+// closed. Invalid acknowledgements are delivered, but don't stop reading.
+func readAcks(r *bufio.Reader, acks chan<- ackResult, stop <-chan struct{}) {
+	defer close(acks)
+	for {
+		ack, err := readAck(r)
+		select {
+		case acks <- ackResult{ack, err}:
+		case <-stop:
+			return
+		}
+		if err != nil && !errors.Is(err, ErrInvalidAck) {
+			return
+		}
+	}
+}
+
+// readAck reads up to and including the "x" that ends an acknowledgement,
+// skipping any whitespace before it.
+func readAck(r *bufio.Reader) (Ack, error) {
+	var b strings.Builder
+	for b.Len() < maxAckSize {
+		ch, err := r.ReadByte()
+		if err != nil {
+			return Ack{}, err
+		}
+		if b.Len() == 0 && (ch == ' ' || ch == '\r' || ch == '\n') {
+			continue
+		}
+		b.WriteByte(ch)
+		if ch == 'x' {
+			return ParseAck(b.String())
+		}
+	}
+	return Ack{}, fmt.Errorf("%w: %q", ErrInvalidAck, b.String())
+}
+
+// CommandError describes a command that did not complete successfully.
+type CommandError struct {
+	Command string
+	Ack     Ack
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%q: %v", e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// GetState returns a snapshot of the device state, as of the last command
+// that reported each field.
+func (c *Client) GetState() State {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.state
+}
+
+func (c *Client) updateState(fn func(*State)) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	fn(&c.state)
+}
+
+func (c *Client) setError(err error) {
+	c.updateState(func(s *State) {
+		s.LastError = err.Error()
+		s.UpdatedAt.LastError = time.Now()
+	})
+}
+
+// Refresh reads the power state, and if the device is on, the volume, mute
+// and input.
+func (c *Client) Refresh(ctx context.Context) error {
+	power, err := c.Power(ctx)
+	if err != nil || power != ip.PowerStateOn {
+		return err
+	}
+	if _, err := c.Volume(ctx); err != nil {
+		return err
+	}
+	if _, err := c.Muted(ctx); err != nil {
+		return err
+	}
+	_, err = c.Input(ctx)
+	return err
+}
+
+// ErrUnknownInput is returned for input names that cannot be selected.
+var ErrUnknownInput = errors.New("unknown input")
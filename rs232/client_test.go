@@ -0,0 +1,336 @@
+package rs232_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+	"go.chrisrx.dev/webos/rs232"
+	"go.chrisrx.dev/webos/rs232/rs232test"
+)
+
+func newTestClient(t *testing.T) (*rs232test.Server, *rs232.Client) {
+	t.Helper()
+
+	display, err := rs232test.NewServer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = display.Close() })
+
+	client := rs232.Dial(display.Addr, rs232.WithTimeout(200*time.Millisecond))
+	t.Cleanup(func() { _ = client.Close() })
+	return display, client
+}
+
+func TestPower(t *testing.T) {
+	display, client := newTestClient(t)
+	ctx := context.Background()
+
+	if power, err := client.Power(ctx); err != nil || power != ip.PowerStateOn {
+		t.Fatalf("expected power %q, received %q: %v", ip.PowerStateOn, power, err)
+	}
+	if err := client.PowerOff(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if display.State().Power {
+		t.Error("expected display to be off")
+	}
+	if power, err := client.Power(ctx); err != nil || power != ip.PowerStateStandby {
+		t.Fatalf("expected power %q, received %q: %v", ip.PowerStateStandby, power, err)
+	}
+
+	// Only the power command works in standby.
+	if _, err := client.Volume(ctx); !errors.Is(err, rs232.ErrNG) {
+		t.Errorf("expected ErrNG in standby, received %v", err)
+	}
+
+	if err := client.PowerOn(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !display.State().Power {
+		t.Error("expected display to be on")
+	}
+	if power := client.GetState().Power; power != ip.PowerStateOn {
+		t.Errorf("expected power %q, received %q", ip.PowerStateOn, power)
+	}
+}
+
+func TestVolume(t *testing.T) {
+	display, client := newTestClient(t)
+	ctx := context.Background()
+
+	if level, err := client.Volume(ctx); err != nil || level != 10 {
+		t.Fatalf("expected volume 10, received %d: %v", level, err)
+	}
+	for _, tc := range []struct {
+		set      func() error
+		expected int
+	}{
+		{func() error { return client.SetVolume(ctx, 30) }, 30},
+		{func() error { return client.StepVolume(ctx, 5) }, 35},
+		{func() error { return client.StepVolume(ctx, -10) }, 25},
+		{func() error { return client.SetVolume(ctx, 150) }, ip.MaxVolume},
+		{func() error { return client.StepVolume(ctx, 1) }, ip.MaxVolume},
+		{func() error { return client.SetVolume(ctx, -1) }, ip.MinVolume},
+	} {
+		if err := tc.set(); err != nil {
+			t.Fatal(err)
+		}
+		if volume := display.State().Volume; volume != tc.expected {
+			t.Errorf("expected display volume %d, received %d", tc.expected, volume)
+		}
+		if volume := client.GetState().CurrentVolume; volume != int64(tc.expected) {
+			t.Errorf("expected client volume %d, received %d", tc.expected, volume)
+		}
+	}
+}
+
+func TestMute(t *testing.T) {
+	display, client := newTestClient(t)
+	ctx := context.Background()
+
+	if muted, err := client.Muted(ctx); err != nil || muted {
+		t.Fatalf("expected unmuted, received %t: %v", muted, err)
+	}
+	for _, tc := range []struct {
+		set      func(context.Context) error
+		expected bool
+	}{
+		{client.Mute, true},
+		{client.Unmute, false},
+		{client.ToggleMute, true},
+		{client.ToggleMute, false},
+	} {
+		if err := tc.set(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if muted := display.State().Muted; muted != tc.expected {
+			t.Errorf("expected display muted %t, received %t", tc.expected, muted)
+		}
+		if muted := client.GetState().MuteState; muted != tc.expected {
+			t.Errorf("expected client muted %t, received %t", tc.expected, muted)
+		}
+	}
+}
+
+func TestInput(t *testing.T) {
+	display, client := newTestClient(t)
+	ctx := context.Background()
+
+	if app, err := client.Input(ctx); err != nil || app != "com.webos.app.hdmi1" {
+		t.Fatalf("expected com.webos.app.hdmi1, received %q: %v", app, err)
+	}
+	for _, tc := range []struct {
+		name     string
+		data     byte
+		expected string
+	}{
+		{"hdmi2", 0x91, "com.webos.app.hdmi2"},
+		{" HDMI4 ", 0x93, "com.webos.app.hdmi4"},
+		{"dtv", 0x00, "com.webos.app.livetv"},
+		{"av1", 0x20, "com.webos.app.externalinput.av1"},
+	} {
+		if err := client.ChangeInput(ctx, tc.name); err != nil {
+			t.Fatal(err)
+		}
+		if input := display.State().Input; input != tc.data {
+			t.Errorf("%s: expected display input %02x, received %02x", tc.name, tc.data, input)
+		}
+		if app, err := client.Input(ctx); err != nil || app != tc.expected {
+			t.Errorf("%s: expected %q, received %q: %v", tc.name, tc.expected, app, err)
+		}
+	}
+
+	before := len(display.Commands())
+	if err := client.ChangeInput(ctx, "hdmi9"); !errors.Is(err, rs232.ErrUnknownInput) {
+		t.Errorf("expected ErrUnknownInput, received %v", err)
+	}
+	if after := len(display.Commands()); after != before {
+		t.Error("expected unknown input not to be sent")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	display, client := newTestClient(t)
+	display.SetState(func(s *rs232test.State) {
+		s.Volume, s.Muted, s.Input = 42, true, 0x92
+	})
+
+	if err := client.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	state := client.GetState()
+	if !state.Connected || state.Power != ip.PowerStateOn || state.CurrentVolume != 42 || !state.MuteState || state.CurrentApp != "com.webos.app.hdmi3" {
+		t.Errorf("unexpected state: %+v", state)
+	}
+}
+
+func TestNG(t *testing.T) {
+	display, client := newTestClient(t)
+	display.SetFaults(rs232test.Faults{NG: []rs232.Command{rs232.CommandVolume}})
+
+	err := client.SetVolume(context.Background(), 30)
+	var cmdErr *rs232.CommandError
+	if !errors.As(err, &cmdErr) || !errors.Is(err, rs232.ErrNG) {
+		t.Fatalf("expected *CommandError wrapping ErrNG, received %v", err)
+	}
+	if cmdErr.Command != "kf 01 1e" {
+		t.Errorf("expected command %q, received %q", "kf 01 1e", cmdErr.Command)
+	}
+	if volume := client.GetState().CurrentVolume; volume != 0 {
+		t.Errorf("expected volume to be unchanged, received %d", volume)
+	}
+	if client.GetState().LastError == "" {
+		t.Error("expected last error to be set")
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	display, client := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := client.Volume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	display.DropConnections()
+
+	// The dropped connection is only noticed when the next command is sent,
+	// which is retried on a new connection.
+	if err := client.SetVolume(ctx, 20); err != nil {
+		t.Fatal(err)
+	}
+	if volume := display.State().Volume; volume != 20 {
+		t.Errorf("expected volume 20, received %d", volume)
+	}
+	if !client.GetState().Connected {
+		t.Error("expected client to be connected")
+	}
+}
+
+func TestNoResponse(t *testing.T) {
+	display, client := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := client.Volume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	display.SetFaults(rs232test.Faults{DropResponses: true})
+
+	// Timeouts are not retried, since the display may have applied the
+	// command.
+	before := len(display.Commands())
+	if err := client.SetVolume(ctx, 20); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected timeout, received %v", err)
+	}
+	if sent := len(display.Commands()) - before; sent != 1 {
+		t.Errorf("expected command to be sent once, sent %d times", sent)
+	}
+
+	// The connection is replaced, so a late acknowledgement is never
+	// mistaken for that of the next command.
+	display.SetFaults(rs232test.Faults{})
+	if level, err := client.Volume(ctx); err != nil || level != 10 {
+		t.Errorf("expected volume 10, received %d: %v", level, err)
+	}
+}
+
+func TestSetID(t *testing.T) {
+	display, err := rs232test.NewServer(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer display.Close()
+
+	// Commands for other displays are ignored.
+	other := rs232.Dial(display.Addr, rs232.WithTimeout(200*time.Millisecond))
+	defer other.Close()
+	if _, err := other.Volume(context.Background()); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected timeout, received %v", err)
+	}
+
+	for _, id := range []int{2, 0} {
+		client := rs232.Dial(display.Addr, rs232.WithSetID(id))
+		if _, err := client.Volume(context.Background()); err != nil {
+			t.Errorf("set ID %d: %v", id, err)
+		}
+		_ = client.Close()
+	}
+}
+
+// The acknowledgement must be for the same command and set ID as the command
+// that was sent.
+func TestMismatchedAck(t *testing.T) {
+	for _, ack := range []string{"a 02 OK01x", "f 01 OK01x"} {
+		conn, device := net.Pipe()
+		go func() {
+			_, _ = bufio.NewReader(device).ReadString('\r')
+			_, _ = device.Write([]byte(ack))
+		}()
+
+		client := rs232.New(conn, rs232.WithTimeout(time.Second))
+		if _, err := client.Power(context.Background()); !errors.Is(err, rs232.ErrInvalidAck) {
+			t.Errorf("%q: expected ErrInvalidAck, received %v", ack, err)
+		}
+		_ = client.Close()
+		_ = device.Close()
+	}
+}
+
+// pipe is a stream without deadlines, like some serial ports.
+type pipe struct {
+	io.Reader
+	io.Writer
+}
+
+// A device that never responds must not block the client, even on a stream
+// whose reads cannot be interrupted.
+func TestNoResponseWithoutDeadline(t *testing.T) {
+	commands, device := io.Pipe()
+	acks, reply := io.Pipe()
+	defer reply.Close()
+
+	var respond atomic.Bool
+	go func() {
+		r := bufio.NewReader(commands)
+		for {
+			command, err := r.ReadString('\r')
+			if err != nil {
+				return
+			}
+			if respond.Load() {
+				_, _ = fmt.Fprintf(reply, "%c %s OK01x", command[1], command[3:5])
+			}
+		}
+	}()
+
+	client := rs232.New(pipe{acks, device}, rs232.WithTimeout(200*time.Millisecond))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.Power(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, received %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("expected the command to be canceled with the context, returned after %v", elapsed)
+	}
+
+	if _, err := client.Power(context.Background()); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected timeout, received %v", err)
+	}
+
+	respond.Store(true)
+	if power, err := client.Power(context.Background()); err != nil || power != ip.PowerStateOn {
+		t.Errorf("expected power %q, received %q: %v", ip.PowerStateOn, power, err)
+	}
+}
@@ -0,0 +1,167 @@
+package rs232
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.chrisrx.dev/webos/ip"
+)
+
+// Power reads the power state of the device. The device can only respond while
+// it is on or in standby, so it is either ip.PowerStateOn or
+// ip.PowerStateStandby.
+func (c *Client) Power(ctx context.Context) (ip.PowerState, error) {
+	ack, err := c.Send(ctx, CommandPower, readData)
+	if err != nil {
+		return ip.PowerStateUnknown, err
+	}
+	power := ip.PowerStateStandby
+	if ack.Data == 0x01 {
+		power = ip.PowerStateOn
+	}
+	c.setPower(power)
+	return power, nil
+}
+
+func (c *Client) PowerOn(ctx context.Context) error {
+	if _, err := c.Send(ctx, CommandPower, 0x01); err != nil {
+		return err
+	}
+	c.setPower(ip.PowerStateOn)
+	return nil
+}
+
+func (c *Client) PowerOff(ctx context.Context) error {
+	if _, err := c.Send(ctx, CommandPower, 0x00); err != nil {
+		return err
+	}
+	c.setPower(ip.PowerStateStandby)
+	return nil
+}
+
+func (c *Client) setPower(power ip.PowerState) {
+	c.updateState(func(s *State) {
+		s.Power = power
+		s.UpdatedAt.Power = time.Now()
+	})
+}
+
+// Volume reads the current volume level.
+func (c *Client) Volume(ctx context.Context) (int, error) {
+	ack, err := c.Send(ctx, CommandVolume, readData)
+	if err != nil {
+		return 0, err
+	}
+	c.setVolume(int(ack.Data))
+	return int(ack.Data), nil
+}
+
+// SetVolume sets the absolute volume level, clamped to the range supported by
+// the device.
+func (c *Client) SetVolume(ctx context.Context, level int) error {
+	level = min(max(level, ip.MinVolume), ip.MaxVolume)
+	if _, err := c.Send(ctx, CommandVolume, byte(level)); err != nil {
+		return err
+	}
+	c.setVolume(level)
+	return nil
+}
+
+// StepVolume changes the volume relative to the current level. Positive steps
+// increase the volume, negative steps decrease it.
+func (c *Client) StepVolume(ctx context.Context, steps int) error {
+	level, err := c.Volume(ctx)
+	if err != nil {
+		return err
+	}
+	return c.SetVolume(ctx, level+steps)
+}
+
+func (c *Client) setVolume(level int) {
+	c.updateState(func(s *State) {
+		s.CurrentVolume = int64(level)
+		s.UpdatedAt.CurrentVolume = time.Now()
+	})
+}
+
+// Muted reads whether the volume is muted.
+func (c *Client) Muted(ctx context.Context) (bool, error) {
+	ack, err := c.Send(ctx, CommandVolumeMute, readData)
+	if err != nil {
+		return false, err
+	}
+	// The data is whether the volume is on, so 00 means muted.
+	muted := ack.Data == 0x00
+	c.setMute(muted)
+	return muted, nil
+}
+
+func (c *Client) Mute(ctx context.Context) error {
+	return c.sendMute(ctx, true)
+}
+
+func (c *Client) Unmute(ctx context.Context) error {
+	return c.sendMute(ctx, false)
+}
+
+// ToggleMute inverts the current mute state, as reported by the device.
+func (c *Client) ToggleMute(ctx context.Context) error {
+	muted, err := c.Muted(ctx)
+	if err != nil {
+		return err
+	}
+	return c.sendMute(ctx, !muted)
+}
+
+func (c *Client) sendMute(ctx context.Context, mute bool) error {
+	data := byte(0x01)
+	if mute {
+		data = 0x00
+	}
+	if _, err := c.Send(ctx, CommandVolumeMute, data); err != nil {
+		return err
+	}
+	c.setMute(mute)
+	return nil
+}
+
+func (c *Client) setMute(muted bool) {
+	c.updateState(func(s *State) {
+		s.MuteState = muted
+		s.UpdatedAt.MuteState = time.Now()
+	})
+}
+
+// Input reads the current input, returned as the app ID reported for it by
+// the IP control protocol (e.g. "com.webos.app.hdmi1").
+func (c *Client) Input(ctx context.Context) (string, error) {
+	ack, err := c.Send(ctx, CommandInputSelect, readData)
+	if err != nil {
+		return "", err
+	}
+	app := inputApp(ack.Data)
+	c.setInput(app)
+	return app, nil
+}
+
+// ChangeInput selects the input with the provided name, one of Inputs().
+func (c *Client) ChangeInput(ctx context.Context, name string) error {
+	data, ok := inputs[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownInput, name)
+	}
+	if _, err := c.Send(ctx, CommandInputSelect, data); err != nil {
+		return err
+	}
+	c.setInput(inputApp(data))
+	return nil
+}
+
+func (c *Client) setInput(app string) {
+	c.updateState(func(s *State) {
+		s.CurrentApp = app
+		s.UpdatedAt.CurrentApp = time.Now()
+	})
+}
@@ -0,0 +1,111 @@
+package rs232
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+var (
+	// ErrNG is returned when the device explicitly rejected the command.
+	ErrNG = errors.New("device responded NG")
+
+	// ErrInvalidAck is returned when the response is not a well-formed
+	// acknowledgement for the command that was sent.
+	ErrInvalidAck = errors.New("invalid acknowledgement")
+)
+
+// readData is sent in place of a value to read the current setting.
+const readData = 0xff
+
+// Command is a two letter command, such as "ka" for power.
+type Command string
+
+const (
+	CommandPower       Command = "ka"
+	CommandScreenMute  Command = "kd"
+	CommandVolumeMute  Command = "ke"
+	CommandVolume      Command = "kf"
+	CommandInputSelect Command = "xb"
+)
+
+// Encode returns the command line for the set ID and data, e.g. "ka 01 01\r".
+// A set ID of 0 addresses every device on the bus.
+func Encode(cmd Command, setID int, data byte) []byte {
+	return fmt.Appendf(nil, "%s %02x %02x\r", cmd, setID, data)
+}
+
+// Ack is an acknowledgement sent by the device in response to a command,
+// e.g. "a 01 OK01x".
+type Ack struct {
+	// Command is the second letter of the command being acknowledged.
+	Command byte
+	SetID   int
+	OK      bool
+	Data    byte
+}
+
+var ackPattern = regexp.MustCompile(`^([a-z]) ([0-9a-fA-F]{2}) (OK|NG)([0-9a-fA-F]{2})x$`)
+
+// ParseAck parses an acknowledgement, which must include the trailing "x".
+func ParseAck(s string) (Ack, error) {
+	m := ackPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Ack{}, fmt.Errorf("%w: %q", ErrInvalidAck, s)
+	}
+	setID, _ := strconv.ParseUint(m[2], 16, 8)
+	data, _ := strconv.ParseUint(m[4], 16, 8)
+	return Ack{
+		Command: m[1][0],
+		SetID:   int(setID),
+		OK:      m[3] == "OK",
+		Data:    byte(data),
+	}, nil
+}
+
+func (a Ack) String() string {
+	status := "NG"
+	if a.OK {
+		status = "OK"
+	}
+	return fmt.Sprintf("%c %02x %s%02xx", a.Command, a.SetID, status, a.Data)
+}
+
+// inputs maps input names, as used by ip.Client, to xb data values.
+var inputs = map[string]byte{
+	"dtv":        0x00,
+	"atv":        0x10,
+	"av1":        0x20,
+	"component1": 0x40,
+	"hdmi1":      0x90,
+	"hdmi2":      0x91,
+	"hdmi3":      0x92,
+	"hdmi4":      0x93,
+}
+
+// Inputs returns the names of the inputs that can be selected.
+func Inputs() []string {
+	return slices.Sorted(maps.Keys(inputs))
+}
+
+// inputApp returns the CURRENT_APP value reported by the IP control protocol
+// for the input, so that State is comparable between both protocols.
+func inputApp(data byte) string {
+	switch data {
+	case 0x00, 0x01, 0x02, 0x10, 0x11:
+		return "com.webos.app.livetv"
+	case 0x20:
+		return "com.webos.app.externalinput.av1"
+	case 0x40:
+		return "com.webos.app.externalinput.component"
+	}
+	for name, v := range inputs {
+		if v == data {
+			return "com.webos.app." + name
+		}
+	}
+	return fmt.Sprintf("input:%02x", data)
+}
@@ -0,0 +1,83 @@
+package rs232
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		cmd      Command
+		setID    int
+		data     byte
+		expected string
+	}{
+		{CommandPower, 1, 0x01, "ka 01 01\r"},
+		{CommandVolume, 1, 100, "kf 01 64\r"},
+		{CommandInputSelect, 0x1f, 0x90, "xb 1f 90\r"},
+		{CommandVolumeMute, 0, readData, "ke 00 ff\r"},
+	} {
+		if line := string(Encode(tc.cmd, tc.setID, tc.data)); line != tc.expected {
+			t.Errorf("expected %q, received %q", tc.expected, line)
+		}
+	}
+}
+
+func TestParseAck(t *testing.T) {
+	cases := []struct {
+		ack      string
+		expected Ack
+		err      error
+	}{
+		{ack: "a 01 OK01x", expected: Ack{Command: 'a', SetID: 1, OK: true, Data: 0x01}},
+		{ack: "f 01 OK64x", expected: Ack{Command: 'f', SetID: 1, OK: true, Data: 100}},
+		{ack: "b 1F OK9Ax", expected: Ack{Command: 'b', SetID: 0x1f, OK: true, Data: 0x9a}},
+		{ack: "a 02 OK00x", expected: Ack{Command: 'a', SetID: 2, OK: true}},
+		{ack: "e 01 NG05x", expected: Ack{Command: 'e', SetID: 1, Data: 0x05}},
+		{ack: "a 01 OK01", err: ErrInvalidAck},
+		{ack: "a 01 OK01x\r", err: ErrInvalidAck},
+		{ack: "a 1 OK01x", err: ErrInvalidAck},
+		{ack: "a 01 OK1x", err: ErrInvalidAck},
+		{ack: "A 01 OK01x", err: ErrInvalidAck},
+		{ack: "a 01 XX01x", err: ErrInvalidAck},
+		{ack: "a 0g OK01x", err: ErrInvalidAck},
+		{ack: "", err: ErrInvalidAck},
+	}
+	for _, tc := range cases {
+		ack, err := ParseAck(tc.ack)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("ParseAck(%q): expected %v, received %v", tc.ack, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAck(%q): %v", tc.ack, err)
+			continue
+		}
+		if ack != tc.expected {
+			t.Errorf("ParseAck(%q): expected %+v, received %+v", tc.ack, tc.expected, ack)
+		}
+		// Acknowledgements are formatted with lowercase hex.
+		if s := ack.String(); !strings.EqualFold(s, tc.ack) {
+			t.Errorf("expected %q, received %q", tc.ack, s)
+		}
+	}
+}
+
+func TestInputApp(t *testing.T) {
+	for data, expected := range map[byte]string{
+		0x00: "com.webos.app.livetv",
+		0x10: "com.webos.app.livetv",
+		0x20: "com.webos.app.externalinput.av1",
+		0x40: "com.webos.app.externalinput.component",
+		0x90: "com.webos.app.hdmi1",
+		0x93: "com.webos.app.hdmi4",
+		0xa0: "input:a0",
+	} {
+		if app := inputApp(data); app != expected {
+			t.Errorf("inputApp(%02x): expected %q, received %q", data, expected, app)
+		}
+	}
+}
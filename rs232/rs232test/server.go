@@ -0,0 +1,266 @@
+// Package rs232test provides a fake LG display that speaks the RS-232 protocol
+// over TCP, like a serial-to-TCP bridge, for testing clients of the rs232
+// package without real hardware.
+package rs232test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.chrisrx.dev/webos/ip"
+	"go.chrisrx.dev/webos/rs232"
+)
+
+// State is the simulated state of the display.
+type State struct {
+	Power  bool
+	Volume int
+	Muted  bool
+	Input  byte
+}
+
+func defaultState() State {
+	return State{
+		Power:  true,
+		Volume: 10,
+		Input:  0x90,
+	}
+}
+
+// Faults are injected into the behavior of the server.
+type Faults struct {
+	// DropResponses causes commands to be received but never acknowledged.
+	DropResponses bool
+
+	// NG is a list of commands that are always acknowledged with NG.
+	NG []rs232.Command
+}
+
+type Server struct {
+	// Addr is the address the server is listening on.
+	Addr string
+
+	l      net.Listener
+	setID  int
+	logger *slog.Logger
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	state    State
+	faults   Faults
+	conns    map[net.Conn]struct{}
+	commands []string
+}
+
+// NewServer starts a fake display with the set ID listening on a random local
+// port.
+func NewServer(setID int) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:   l.Addr().String(),
+		l:      l,
+		setID:  setID,
+		logger: slog.Default().With(slog.String("component", "rs232test")),
+		state:  defaultState(),
+		conns:  make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and closes all active connections.
+func (s *Server) Close() error {
+	err := s.l.Close()
+	s.DropConnections()
+	s.wg.Wait()
+	return err
+}
+
+// State returns the current simulated state.
+func (s *Server) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state
+}
+
+// SetState modifies the simulated state.
+func (s *Server) SetState(fn func(*State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.state)
+}
+
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = f
+}
+
+// DropConnections closes all active connections, as happens when the bridge
+// loses its network connection.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// Commands returns every command received so far, in order.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.commands)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\r')
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Debug("cannot read command", slog.Any("error", err))
+			}
+			return
+		}
+		ack, ok := s.handle(strings.TrimSpace(line))
+		if !ok {
+			continue
+		}
+		if _, err := conn.Write([]byte(ack.String())); err != nil {
+			return
+		}
+	}
+}
+
+// handle applies the command to the simulated state and returns the
+// acknowledgement. It returns false if no acknowledgement should be sent.
+func (s *Server) handle(line string) (rs232.Ack, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = append(s.commands, line)
+	if s.faults.DropResponses {
+		return rs232.Ack{}, false
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 3 || len(fields[0]) != 2 {
+		return rs232.Ack{}, false
+	}
+	cmd := rs232.Command(fields[0])
+	setID, err1 := strconv.ParseUint(fields[1], 16, 8)
+	data, err2 := strconv.ParseUint(fields[2], 16, 8)
+	if err1 != nil || err2 != nil {
+		return rs232.Ack{}, false
+	}
+	// Commands for other displays on the bus are ignored.
+	if setID != 0 && int(setID) != s.setID {
+		return rs232.Ack{}, false
+	}
+	ack := rs232.Ack{Command: cmd[1], SetID: s.setID, Data: byte(data)}
+	h, ok := handlers[cmd]
+	if !ok || slices.Contains(s.faults.NG, cmd) {
+		return ack, true
+	}
+	// Only the power command works while the display is in standby.
+	if !s.state.Power && cmd != rs232.CommandPower {
+		return ack, true
+	}
+	ack.Data, ack.OK = h(&s.state, byte(data))
+	return ack, true
+}
+
+const readData = 0xff
+
+var handlers = map[rs232.Command]func(s *State, data byte) (byte, bool){
+	rs232.CommandPower: func(s *State, data byte) (byte, bool) {
+		switch data {
+		case 0x00:
+			s.Power = false
+		case 0x01:
+			s.Power = true
+		case readData:
+		default:
+			return data, false
+		}
+		return formatBool(s.Power), true
+	},
+	rs232.CommandVolume: func(s *State, data byte) (byte, bool) {
+		if data != readData {
+			if int(data) > ip.MaxVolume {
+				return data, false
+			}
+			s.Volume = int(data)
+		}
+		return byte(s.Volume), true
+	},
+	rs232.CommandVolumeMute: func(s *State, data byte) (byte, bool) {
+		switch data {
+		case 0x00:
+			s.Muted = true
+		case 0x01:
+			s.Muted = false
+		case readData:
+		default:
+			return data, false
+		}
+		// The data is whether the volume is on.
+		return formatBool(!s.Muted), true
+	},
+	rs232.CommandInputSelect: func(s *State, data byte) (byte, bool) {
+		if data != readData {
+			s.Input = data
+		}
+		return s.Input, true
+	},
+}
+
+func formatBool(v bool) byte {
+	if v {
+		return 0x01
+	}
+	return 0x00
+}
@@ -0,0 +1,87 @@
+package ssap
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type Channel struct {
+	ID     string `json:"channelId"`
+	Number string `json:"channelNumber"`
+	Name   string `json:"channelName"`
+}
+
+type Program struct {
+	Name        string `json:"programName"`
+	Description string `json:"description"`
+	StartTime   string `json:"localStartTime"`
+	EndTime     string `json:"localEndTime"`
+}
+
+// ChannelList returns the channels found by the tuner.
+func (c *Client) ChannelList(ctx context.Context) ([]Channel, error) {
+	resp, err := c.Request(ctx, TVGetChannelList, nil)
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		ChannelList []Channel `json:"channelList"`
+	}
+	if err := decode(resp, &v); err != nil {
+		return nil, err
+	}
+	return v.ChannelList, nil
+}
+
+// CurrentChannel returns the channel currently tuned to.
+func (c *Client) CurrentChannel(ctx context.Context) (Channel, error) {
+	resp, err := c.Request(ctx, TVGetCurrentChannel, nil)
+	if err != nil {
+		return Channel{}, err
+	}
+	var ch Channel
+	if err := decode(resp, &ch); err != nil {
+		return Channel{}, err
+	}
+	return ch, nil
+}
+
+// ChannelProgramInfo returns the program guide for the current channel.
+func (c *Client) ChannelProgramInfo(ctx context.Context) ([]Program, error) {
+	resp, err := c.Request(ctx, TVGetChannelProgramInfo, nil)
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		ProgramList []Program `json:"programList"`
+	}
+	if err := decode(resp, &v); err != nil {
+		return nil, err
+	}
+	return v.ProgramList, nil
+}
+
+// OpenChannel tunes to the channel with the ID returned by ChannelList.
+func (c *Client) OpenChannel(ctx context.Context, id string) error {
+	_, err := c.Request(ctx, TVOpenChannel, map[string]any{"channelId": id})
+	return err
+}
+
+func (c *Client) ChannelUp(ctx context.Context) error {
+	_, err := c.Request(ctx, TVChannelUp, nil)
+	return err
+}
+
+func (c *Client) ChannelDown(ctx context.Context) error {
+	_, err := c.Request(ctx, TVChannelDown, nil)
+	return err
+}
+
+// decode converts a response payload into v.
+func decode(payload map[string]any, v any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -93,3 +93,24 @@ func (c *Client) Button(name string) error {
 	}
 	return nil
 }
+
+// TurnOffScreen blanks the screen while audio keeps playing.
+func (c *Client) TurnOffScreen(ctx context.Context) error {
+	_, err := c.Request(ctx, TVPowerTurnOffScreen, nil)
+	return err
+}
+
+func (c *Client) TurnOnScreen(ctx context.Context) error {
+	_, err := c.Request(ctx, TVPowerTurnOnScreen, nil)
+	return err
+}
+
+// IsScreenOff reports whether the screen is currently turned off.
+func (c *Client) IsScreenOff(ctx context.Context) (bool, error) {
+	resp, err := c.Request(ctx, TVPowerGetPowerState, nil)
+	if err != nil {
+		return false, err
+	}
+	state, _ := resp["state"].(string)
+	return state == "Screen Off", nil
+}
@@ -8,4 +8,13 @@ const (
 	AudioGetVolume                         Command = "ssap://audio/getVolume"
 	GetPointerInputSocket                  Command = "ssap://com.webos.service.networkinput/getPointerInputSocket"
 	SendEnterKey                           Command = "ssap://com.webos.service.ime/sendEnterKey"
+	TVChannelDown                          Command = "ssap://tv/channelDown"
+	TVChannelUp                            Command = "ssap://tv/channelUp"
+	TVGetChannelList                       Command = "ssap://tv/getChannelList"
+	TVGetChannelProgramInfo                Command = "ssap://tv/getChannelProgramInfo"
+	TVGetCurrentChannel                    Command = "ssap://tv/getCurrentChannel"
+	TVOpenChannel                          Command = "ssap://tv/openChannel"
+	TVPowerGetPowerState                   Command = "ssap://com.webos.service.tvpower/power/getPowerState"
+	TVPowerTurnOffScreen                   Command = "ssap://com.webos.service.tvpower/power/turnOffScreen"
+	TVPowerTurnOnScreen                    Command = "ssap://com.webos.service.tvpower/power/turnOnScreen"
 )